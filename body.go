@@ -0,0 +1,25 @@
+package httpmock
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+)
+
+// ReadBody reads the whole body of req and replaces req.Body with a fresh reader over the same
+// bytes, so it can be called any number of times, from matchers as well as responders, without
+// preventing downstream reads.  A request without body gives a nil slice.
+func ReadBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,34 @@
+package httpmock
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReadBody(t *testing.T) {
+	req, err := http.NewRequest("POST", testUrl, strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		body, err := ReadBody(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(body) != "hello world" {
+			t.Fatalf("read #%d: expected body %q, got %q", i+1, "hello world", body)
+		}
+	}
+
+	req, err = http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body, err := ReadBody(req); err != nil || body != nil {
+		t.Fatalf("expected no body and no error, got %q, %v", body, err)
+	}
+}
@@ -0,0 +1,135 @@
+package httpmock
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// compressors holds the body encoders supported by NewCompressedResponder, keyed by their
+// Content-Encoding.  "br" is only available when building with the brotli tag, see
+// compress_brotli.go.
+var compressors = map[string]func(io.Writer) (io.WriteCloser, error){
+	"gzip": func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	},
+	// the deflate content coding is the zlib format (RFC 9110 section 8.4.1.2), not raw DEFLATE
+	"deflate": func(w io.Writer) (io.WriteCloser, error) {
+		return zlib.NewWriter(w), nil
+	},
+}
+
+// compress encodes body with the compressor registered for encoding.
+func compress(body []byte, encoding string) ([]byte, error) {
+	newWriter, ok := compressors[encoding]
+	if !ok {
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	var buf bytes.Buffer
+	w, err := newWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// NewCompressedResponse creates an *http.Response whose body is body compressed with the given
+// encoding, and whose Content-Encoding header is set accordingly.  Supported encodings are
+// "gzip" and "deflate", the latter being zlib-wrapped as HTTP requires.  "br" (Brotli) needs the
+// third-party package github.com/andybalholm/brotli, so it is only supported when building with
+// the brotli tag:
+//
+//	go get github.com/andybalholm/brotli
+//	go test -tags brotli
+//
+// Any other encoding gives an error.
+func NewCompressedResponse(status int, body []byte, encoding string) (*http.Response, error) {
+	compressed, err := compress(body, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	response := NewBytesResponse(status, compressed)
+	response.Header.Set("Content-Encoding", encoding)
+	return response, nil
+}
+
+// NewCompressedResponder creates a Responder from a body compressed once, at construction, with
+// the given encoding.  See NewCompressedResponse for the supported encodings.
+func NewCompressedResponder(status int, body []byte, encoding string) (Responder, error) {
+	resp, err := NewCompressedResponse(status, body, encoding)
+	if err != nil {
+		return nil, err
+	}
+	return ResponderFromResponse(resp), nil
+}
+
+// NewGzipResponder creates a Responder from a body gzipped once, at construction.  The responses
+// carry a "Content-Encoding: gzip" header, as seen by a client which disabled compression
+// handling.  To mimic the transparent decompression done by http.Transport instead, chain it
+// with Uncompressed(true).
+func NewGzipResponder(status int, body []byte) (Responder, error) {
+	return NewCompressedResponder(status, body, "gzip")
+}
+
+// NewGzipJsonResponder creates a Responder from a given body (as an interface{} that is encoded to
+// json, then gzipped) and status code.  Both the "Content-Type: application/json" and
+// "Content-Encoding: gzip" headers are set.
+func NewGzipJsonResponder(status int, body interface{}) (Responder, error) {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := NewCompressedResponse(status, encoded, "gzip")
+	if err != nil {
+		return nil, err
+	}
+	resp.Header.Set("Content-Type", "application/json")
+	return ResponderFromResponse(resp), nil
+}
+
+// Uncompressed returns a Responder which sets the Uncompressed field of the responses of r to v.
+// When v is true and the response is gzip-encoded, it is decompressed the way http.Transport
+// does it transparently: the body is gunzipped and the Content-Encoding and Content-Length
+// headers are removed.
+func (r Responder) Uncompressed(v bool) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := r(req)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+
+		resp = cloneResponse(resp)
+		resp.Uncompressed = v
+
+		if v && resp.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				return nil, err
+			}
+			body, err := ioutil.ReadAll(zr)
+			if err != nil {
+				return nil, err
+			}
+
+			resp.Body = NewRespBodyFromBytes(body)
+			resp.Header.Del("Content-Encoding")
+			resp.Header.Del("Content-Length")
+			resp.ContentLength = -1
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,16 @@
+//go:build brotli
+// +build brotli
+
+package httpmock
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+func init() {
+	compressors["br"] = func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriter(w), nil
+	}
+}
@@ -0,0 +1,44 @@
+//go:build brotli
+// +build brotli
+
+package httpmock
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNewCompressedResponderBrotli(t *testing.T) {
+	body := []byte("hello world")
+
+	responder, err := NewCompressedResponder(200, body, "br")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := responder(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Header.Get("Content-Encoding") != "br" {
+		t.Fatalf("expected Content-Encoding br, got %q", response.Header.Get("Content-Encoding"))
+	}
+
+	data, err := ioutil.ReadAll(brotli.NewReader(response.Body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != string(body) {
+		t.Fatalf("expected body %q, got %q", body, data)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !brotli
+// +build !brotli
+
+package httpmock
+
+import "testing"
+
+func TestNewCompressedResponderNoBrotli(t *testing.T) {
+	if _, err := NewCompressedResponder(200, []byte("hello world"), "br"); err == nil {
+		t.Fatal("expected an error for br without the brotli tag")
+	}
+}
@@ -0,0 +1,151 @@
+package httpmock
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestNewCompressedResponder(t *testing.T) {
+	body := []byte("hello world")
+
+	readers := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+		"deflate": func(r io.Reader) (io.Reader, error) {
+			return zlib.NewReader(r)
+		},
+	}
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for encoding, newReader := range readers {
+		responder, err := NewCompressedResponder(200, body, encoding)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := responder(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Header.Get("Content-Encoding") != encoding {
+			t.Fatalf("expected Content-Encoding %q, got %q", encoding, response.Header.Get("Content-Encoding"))
+		}
+
+		r, err := newReader(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != string(body) {
+			t.Fatalf("%s: expected body %q, got %q", encoding, body, data)
+		}
+	}
+
+	if _, err := NewCompressedResponder(200, body, "compress"); err == nil {
+		t.Fatal("expected an error for an unsupported encoding")
+	}
+}
+
+func TestResponderUncompressed(t *testing.T) {
+	gzipped, err := NewGzipResponder(200, []byte("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := gzipped(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Uncompressed {
+		t.Fatal("expected Uncompressed to be false by default")
+	}
+
+	for i := 0; i < 2; i++ {
+		response, err = gzipped.Uncompressed(true)(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !response.Uncompressed {
+			t.Fatal("expected Uncompressed to be true")
+		}
+
+		if response.Header.Get("Content-Encoding") != "" {
+			t.Fatal("expected Content-Encoding to be removed")
+		}
+
+		data, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != "hello world" {
+			t.Fatalf("expected a decompressed body, got %q", data)
+		}
+	}
+}
+
+func TestNewGzipJsonResponder(t *testing.T) {
+	type schema struct {
+		Hello string `json:"hello"`
+	}
+
+	responder, err := NewGzipJsonResponder(200, &schema{"world"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := responder(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Header.Get("Content-Type") != "application/json" || response.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("unexpected headers %v", response.Header)
+	}
+
+	r, err := gzip.NewReader(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkBody := &schema{}
+	if err := json.NewDecoder(r).Decode(checkBody); err != nil {
+		t.Fatal(err)
+	}
+
+	if checkBody.Hello != "world" {
+		t.FailNow()
+	}
+
+	if _, err := NewGzipJsonResponder(200, make(chan int)); err == nil {
+		t.Fatal("expected a marshaling error")
+	}
+}
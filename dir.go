@@ -0,0 +1,83 @@
+package httpmock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// RegisterResponderDir registers a responder for each JSON fixture file of dir.  The name of
+// each file gives the method and URL it answers to, following the METHOD_host_path.json
+// convention: the name, without its ".json" extension, is split on underscores, the first part
+// being the method, the second one the host and the remaining ones the path segments.  For
+// instance:
+//
+//	GET_api.mybiz.com.json              GET  http(s)://api.mybiz.com/
+//	GET_api.mybiz.com_articles.json     GET  http(s)://api.mybiz.com/articles
+//	POST_api.mybiz.com_v1_articles.json POST http(s)://api.mybiz.com/v1/articles
+//
+// Each fixture is registered for both the http and https schemes.  As underscores separate the
+// parts, they can't appear in hosts nor path segments.  Files without the ".json" extension and
+// sub-directories are ignored.
+//
+// The files are read again on each request, so editing a fixture takes effect immediately.  They
+// are served with a 200 status and a "Content-Type: application/json" header.  Files added to
+// dir after the call are not picked up.
+func (m *MockTransport) RegisterResponderDir(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	type fixture struct {
+		method, hostPath, file string
+	}
+	var fixtures []fixture
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".json" {
+			continue
+		}
+
+		parts := strings.Split(strings.TrimSuffix(name, ".json"), "_")
+		if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("fixture %s doesn't follow the METHOD_host_path.json convention", name)
+		}
+
+		fixtures = append(fixtures, fixture{
+			method:   parts[0],
+			hostPath: parts[1] + "/" + strings.Join(parts[2:], "/"),
+			file:     filepath.Join(dir, name),
+		})
+	}
+
+	for _, f := range fixtures {
+		responder := newFileResponder(f.file)
+		m.RegisterResponder(f.method, "http://"+f.hostPath, responder)
+		m.RegisterResponder(f.method, "https://"+f.hostPath, responder)
+	}
+	return nil
+}
+
+// RegisterResponderDir registers the JSON fixtures of dir on DefaultTransport, see
+// MockTransport.RegisterResponderDir.
+func RegisterResponderDir(dir string) error {
+	return DefaultTransport.RegisterResponderDir(dir)
+}
+
+// newFileResponder creates a Responder serving the JSON file read on each call.
+func newFileResponder(file string) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		response := NewBytesResponse(http.StatusOK, body)
+		response.Header.Set("Content-Type", "application/json")
+		return response, nil
+	}
+}
@@ -0,0 +1,81 @@
+package httpmock
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRegisterResponderDir(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	dir := t.TempDir()
+	fixtures := map[string]string{
+		"GET_api.mybiz.com.json":              `{"root":true}`,
+		"GET_api.mybiz.com_v1_articles.json":  `[{"id":1}]`,
+		"POST_api.mybiz.com_v1_articles.json": `{"id":2}`,
+		"README.md":                           "ignored",
+	}
+	for name, content := range fixtures {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := RegisterResponderDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(u string) string {
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	if body := get("https://api.mybiz.com/"); body != `{"root":true}` {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	if body := get("http://api.mybiz.com/v1/articles"); body != `[{"id":1}]` {
+		t.Fatalf("unexpected body %q", body)
+	}
+
+	resp, err := http.Post("https://api.mybiz.com/v1/articles", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected Content-Type %q", resp.Header.Get("Content-Type"))
+	}
+
+	// edits are picked up without registering again
+	file := filepath.Join(dir, "GET_api.mybiz.com_v1_articles.json")
+	if err := os.WriteFile(file, []byte(`[]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if body := get("https://api.mybiz.com/v1/articles"); body != `[]` {
+		t.Fatalf("expected the edited fixture, got %q", body)
+	}
+}
+
+func TestRegisterResponderDirInvalidName(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "articles.json"), []byte(`[]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewMockTransport().RegisterResponderDir(dir); err == nil {
+		t.Fatal("expected an error for a fixture not following the naming convention")
+	}
+}
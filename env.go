@@ -1,6 +1,7 @@
 package httpmock
 
 import (
+	"log"
 	"os"
 )
 
@@ -9,3 +10,22 @@ var envVarName = "GONOMOCKS"
 func Disabled() bool {
 	return os.Getenv(envVarName) != ""
 }
+
+// Logger is used to log the warnings of this package, e.g. about fragments stripped from
+// registered URLs or registrations done while the mocks are disabled.  Replace it, or change its
+// output, to capture or silence them.
+var Logger = log.New(os.Stderr, "", log.LstdFlags)
+
+// WarnWhenDisabled makes the registrations on DefaultTransport log a warning through Logger when
+// Disabled() is true, as Activate then does nothing and requests reach the real network instead
+// of the registered responders.  This helps noticing a GONOMOCKS environment variable left set.
+var WarnWhenDisabled bool
+
+// warnIfDisabled logs a warning about the registration described by what if m is DefaultTransport
+// and the mocks are disabled, see WarnWhenDisabled.
+func (m *MockTransport) warnIfDisabled(what string) {
+	if WarnWhenDisabled && m == DefaultTransport && Disabled() {
+		Logger.Printf("httpmock: %s registered while %s is set, it won't be used as mocks are disabled",
+			what, envVarName)
+	}
+}
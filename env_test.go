@@ -1,8 +1,11 @@
 package httpmock
 
 import (
+	"bytes"
+	"log"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -42,3 +45,43 @@ func TestEnv(t *testing.T) {
 		t.Fatalf("could not reset %s to it's original value '%s'", envVarName, orig)
 	}
 }
+
+func TestWarnWhenDisabled(t *testing.T) {
+	defer Reset()
+
+	orig := os.Getenv(envVarName)
+	defer os.Setenv(envVarName, orig)
+
+	var buf bytes.Buffer
+	defer func(l *log.Logger) { Logger = l }(Logger)
+	Logger = log.New(&buf, "", 0)
+
+	defer func() { WarnWhenDisabled = false }()
+	WarnWhenDisabled = true
+
+	// enabled: no warning
+	if err := os.Setenv(envVarName, ""); err != nil {
+		t.Fatal(err)
+	}
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "hello"))
+	if buf.Len() != 0 {
+		t.Fatalf("expected no warning, got %q", buf.String())
+	}
+
+	// disabled: warning
+	if err := os.Setenv(envVarName, "1"); err != nil {
+		t.Fatal(err)
+	}
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "hello"))
+	if !strings.Contains(buf.String(), "GET "+testUrl) || !strings.Contains(buf.String(), envVarName) {
+		t.Fatalf("expected a warning, got %q", buf.String())
+	}
+
+	// disabled but not asked to warn
+	buf.Reset()
+	WarnWhenDisabled = false
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "hello"))
+	if buf.Len() != 0 {
+		t.Fatalf("expected no warning, got %q", buf.String())
+	}
+}
@@ -0,0 +1,58 @@
+package httpmock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	grpcWebDataFrame    byte = 0x00
+	grpcWebTrailerFrame byte = 0x80
+)
+
+// NewGRPCWebResponse creates an *http.Response with a gRPC-Web body, as expected by gRPC-Web
+// clients, made of two frames:
+//
+//	0x00 | 4 bytes big-endian length | message
+//	0x80 | 4 bytes big-endian length | trailers
+//
+// The trailers are encoded as HTTP/1 header lines ("name: value\r\n") with lower-cased names,
+// sorted by name.  The Content-Type header is set to "application/grpc-web+proto".
+func NewGRPCWebResponse(status int, message []byte, trailers http.Header) *http.Response {
+	var trailer bytes.Buffer
+	names := make([]string, 0, len(trailers))
+	for name := range trailers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range trailers[name] {
+			trailer.WriteString(strings.ToLower(name) + ": " + v + "\r\n")
+		}
+	}
+
+	var body bytes.Buffer
+	writeGRPCWebFrame(&body, grpcWebDataFrame, message)
+	writeGRPCWebFrame(&body, grpcWebTrailerFrame, trailer.Bytes())
+
+	response := NewBytesResponse(status, body.Bytes())
+	response.Header.Set("Content-Type", "application/grpc-web+proto")
+	return response
+}
+
+// NewGRPCWebResponder creates a Responder from a message and its trailers, see NewGRPCWebResponse.
+func NewGRPCWebResponder(status int, message []byte, trailers http.Header) Responder {
+	return ResponderFromResponse(NewGRPCWebResponse(status, message, trailers))
+}
+
+// writeGRPCWebFrame writes a length-prefixed frame to buf.
+func writeGRPCWebFrame(buf *bytes.Buffer, flag byte, payload []byte) {
+	var header [5]byte
+	header[0] = flag
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	buf.Write(header[:])
+	buf.Write(payload)
+}
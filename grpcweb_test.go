@@ -0,0 +1,56 @@
+package httpmock
+
+import (
+	"encoding/binary"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestNewGRPCWebResponder(t *testing.T) {
+	trailers := http.Header{}
+	trailers.Set("Grpc-Status", "0")
+	trailers.Set("Grpc-Message", "OK")
+
+	req, err := http.NewRequest("POST", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := NewGRPCWebResponder(200, []byte("message"), trailers)(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Header.Get("Content-Type") != "application/grpc-web+proto" {
+		t.Fatalf("unexpected Content-Type %q", response.Header.Get("Content-Type"))
+	}
+
+	expected := []struct {
+		flag    byte
+		payload string
+	}{
+		{0x00, "message"},
+		{0x80, "grpc-message: OK\r\ngrpc-status: 0\r\n"},
+	}
+
+	for _, frame := range expected {
+		var header [5]byte
+		if _, err := io.ReadFull(response.Body, header[:]); err != nil {
+			t.Fatal(err)
+		}
+
+		if header[0] != frame.flag {
+			t.Fatalf("expected flag %#x, got %#x", frame.flag, header[0])
+		}
+
+		payload := make([]byte, binary.BigEndian.Uint32(header[1:]))
+		if _, err := io.ReadFull(response.Body, payload); err != nil {
+			t.Fatal(err)
+		}
+
+		if string(payload) != frame.payload {
+			t.Fatalf("expected payload %q, got %q", frame.payload, payload)
+		}
+	}
+}
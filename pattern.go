@@ -0,0 +1,116 @@
+package httpmock
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// pathResponder is a responder registered with RegisterPathResponder.
+type pathResponder struct {
+	// key is the "METHOD pattern" key the calls are counted under.
+	key      string
+	method   string
+	scheme   string
+	host     string
+	segments []string
+	fn       func(params map[string]string, req *http.Request) (*http.Response, error)
+}
+
+// RegisterPathResponder adds a responder for the given HTTP method and URL pattern, whose path
+// segments starting with a colon are named parameters matching any non-empty segment, e.g.
+// "https://api.mybiz.com/users/:id/posts/:postId".  The scheme and host of the request must be
+// the ones of the pattern, and its path must have the same number of segments.  fn is called with
+// the matched segments, unescaped and keyed by name without the colon, e.g. {"id": "42",
+// "postId": "7"}.  The querystring of the request is ignored.
+//
+// Path responders are consulted after the responders registered for the exact URL (see
+// RegisterResponder and RegisterQueryRegexpResponder) and before the prefix and matcher
+// responders.  Among them, the first registered matching one wins.  Calls are counted under the
+// "METHOD pattern" key, the pattern being normalized like the URLs of RegisterResponder.
+func (m *MockTransport) RegisterPathResponder(method, pattern string, fn func(params map[string]string, req *http.Request) (*http.Response, error)) {
+	m.warnIfDisabled("path responder for " + method + " " + pattern)
+
+	pr := pathResponder{key: responderKey(method, pattern), method: method, fn: fn}
+	if u, err := url.Parse(pattern); err == nil {
+		pr.scheme = u.Scheme
+		pr.host = strings.ToLower(u.Host)
+		pr.segments = strings.Split(u.EscapedPath(), "/")
+	} else {
+		pr.segments = strings.Split(pattern, "/")
+	}
+
+	m.mu.Lock()
+	m.pathResponders = append(m.pathResponders, pr)
+	delete(m.usedUp, pr.key)
+	m.mu.Unlock()
+}
+
+// RegisterPathResponder adds a path pattern responder on DefaultTransport, see
+// MockTransport.RegisterPathResponder.
+func RegisterPathResponder(method, pattern string, fn func(params map[string]string, req *http.Request) (*http.Response, error)) {
+	DefaultTransport.RegisterPathResponder(method, pattern, fn)
+}
+
+// pathResponderForRequest returns the first path responder matching req, skipping those in
+// exhausted, along with the key it is registered with.  m.mu must be held.
+func (m *MockTransport) pathResponderForRequest(req *http.Request, exhausted map[string]bool) (string, Responder) {
+	if len(m.pathResponders) == 0 {
+		return "", nil
+	}
+
+	segments := strings.Split(req.URL.EscapedPath(), "/")
+	for _, pr := range m.pathResponders {
+		if pr.method != req.Method || pr.scheme != req.URL.Scheme || exhausted[pr.key] {
+			continue
+		}
+		if host := strings.ToLower(req.URL.Host); pr.host != host &&
+			!(m.IgnorePort && stripPort(pr.host) == stripPort(host)) {
+			continue
+		}
+
+		if params, ok := pr.match(segments); ok {
+			fn := pr.fn
+			return pr.key, func(req *http.Request) (*http.Response, error) {
+				return fn(params, req)
+			}
+		}
+	}
+	return "", nil
+}
+
+// match returns the named parameters of the pattern if segments, the escaped segments of a
+// request path, match it.
+func (pr *pathResponder) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(pr.segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, seg := range pr.segments {
+		if strings.HasPrefix(seg, ":") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			value, err := url.PathUnescape(segments[i])
+			if err != nil {
+				return nil, false
+			}
+			params[seg[1:]] = value
+			continue
+		}
+
+		if mustNormalizePath(seg) != mustNormalizePath(segments[i]) {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// mustNormalizePath is normalizePath returning p untouched if it can't be normalized.
+func mustNormalizePath(p string) string {
+	if normalized, ok := normalizePath(p); ok {
+		return normalized
+	}
+	return p
+}
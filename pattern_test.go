@@ -0,0 +1,84 @@
+package httpmock
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestRegisterPathResponder(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterPathResponder("GET", "https://api.mybiz.com/users/:id/posts/:postId",
+		func(params map[string]string, req *http.Request) (*http.Response, error) {
+			return NewStringResponse(200, "user "+params["id"]+" post "+params["postId"]), nil
+		})
+	RegisterResponder("GET", "https://api.mybiz.com/users/me/posts/latest", NewStringResponder(200, "exact"))
+
+	tests := map[string]string{
+		"https://api.mybiz.com/users/42/posts/7":        "user 42 post 7",
+		"https://API.mybiz.com/users/a%20b/posts/7?x=1": "user a b post 7",
+		"https://api.mybiz.com/users/me/posts/latest":   "exact",
+	}
+
+	for u, expected := range tests {
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatalf("%s: %s", u, err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != expected {
+			t.Fatalf("%s: expected body %q, got %q", u, expected, data)
+		}
+	}
+
+	for _, u := range []string{
+		"http://api.mybiz.com/users/42/posts/7",
+		"https://other.mybiz.com/users/42/posts/7",
+		"https://api.mybiz.com/users/42/posts",
+		"https://api.mybiz.com/users//posts/7",
+		"https://api.mybiz.com/users/42/comments/7",
+	} {
+		if _, err := http.Get(u); err == nil {
+			t.Fatalf("%s: expected no responder to match", u)
+		}
+	}
+
+	if count := GetCallCountInfo()["GET https://api.mybiz.com/users/:id/posts/:postId"]; count != 2 {
+		t.Fatalf("expected 2 calls, got %d", count)
+	}
+}
+
+func TestRegisterPathResponderMixedCaseHost(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterPathResponder("GET", "https://API.MyBiz.com/users/:id",
+		func(params map[string]string, req *http.Request) (*http.Response, error) {
+			return NewStringResponse(200, "user "+params["id"]), nil
+		})
+
+	if err := AssertNotCalled("GET", "https://API.MyBiz.com/users/:id"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := http.Get("https://api.mybiz.com/users/42"); err != nil {
+		t.Fatal(err)
+	}
+
+	if count := GetCallCountInfo()["GET https://api.mybiz.com/users/:id"]; count != 1 {
+		t.Fatalf("expected 1 call under the normalized pattern, got %d", count)
+	}
+
+	for _, pattern := range []string{"https://API.MyBiz.com/users/:id", "https://api.mybiz.com/users/:id"} {
+		if err := AssertNotCalled("GET", pattern); err == nil {
+			t.Fatalf("%s: expected AssertNotCalled to fail for the called path responder", pattern)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package httpmock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// NewRecordingProxyResponder creates a Responder forwarding the requests to the real server at
+// realURL through transport, for record-and-replay workflows.  The scheme and host of the
+// request URL are replaced by the ones of realURL, and its path is appended to the path of
+// realURL, so "http://mocked/api/users?id=1" proxied to "https://real.example.com/v2" goes to
+// "https://real.example.com/v2/api/users?id=1".  If transport is nil, InitialTransport, the
+// transport in place before Activate, is used.
+//
+// sink, if not nil, is called with the method and the original URL of each request, and a copy of
+// the response whose body is buffered, so it can be dumped to fixtures.  The client gets the
+// same response with its own reader over the body.  Errors of the real server are returned
+// as is, without calling sink.
+func NewRecordingProxyResponder(realURL string, transport http.RoundTripper, sink func(method, url string, resp *http.Response)) Responder {
+	target, err := url.Parse(realURL)
+	if err != nil {
+		return func(*http.Request) (*http.Response, error) {
+			return nil, err
+		}
+	}
+
+	return func(req *http.Request) (*http.Response, error) {
+		rt := transport
+		if rt == nil {
+			rt = InitialTransport
+		}
+
+		out := req.Clone(req.Context())
+		u := *req.URL
+		u.Scheme = target.Scheme
+		u.Host = target.Host
+		u.Path = strings.TrimSuffix(target.Path, "/") + req.URL.Path
+		u.RawPath = ""
+		out.URL = &u
+		out.Host = ""
+		out.RequestURI = ""
+
+		resp, err := rt.RoundTrip(out)
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil {
+			return nil, fmt.Errorf("httpmock: proxying to %s: nil response without error", realURL)
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		resp.Request = req
+		resp.Body = NewRespBodyFromBytes(body)
+
+		if sink != nil {
+			recorded := cloneResponse(resp)
+			recorded.Body = NewRespBodyFromBytes(body)
+			sink(req.Method, req.URL.String(), recorded)
+		}
+		return resp, nil
+	}
+}
@@ -0,0 +1,62 @@
+package httpmock
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewRecordingProxyResponder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Real", "yes")
+		w.WriteHeader(201)
+		w.Write([]byte("real " + r.URL.Path + "?" + r.URL.RawQuery))
+	}))
+	defer server.Close()
+
+	Activate()
+	defer DeactivateAndReset()
+
+	var recorded []string
+	RegisterResponder("GET", "https://api.mybiz.com/users", NewRecordingProxyResponder(server.URL+"/v2", server.Client().Transport,
+		func(method, url string, resp *http.Response) {
+			data, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorded = append(recorded, method+" "+url+" "+resp.Header.Get("X-Real")+" "+string(data))
+		}))
+
+	resp, err := http.Get("https://api.mybiz.com/users?id=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != 201 || string(data) != "real /v2/users?id=1" {
+		t.Fatalf("unexpected response %d %q", resp.StatusCode, data)
+	}
+
+	expected := "GET https://api.mybiz.com/users?id=1 yes real /v2/users?id=1"
+	if len(recorded) != 1 || recorded[0] != expected {
+		t.Fatalf("expected recorded %q, got %q", expected, recorded)
+	}
+}
+
+func TestNewRecordingProxyResponderNilResponse(t *testing.T) {
+	responder := NewRecordingProxyResponder("http://real.example.com", &dummyTripper{}, nil)
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := responder(req); err == nil {
+		t.Fatal("expected an error for a nil response")
+	}
+}
@@ -0,0 +1,94 @@
+package httpmock
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// NewRangeResponder creates a Responder serving fullBody while honoring a single-range Range
+// request header, as needed by resumable downloads:
+//   - without Range header, a 200 response with the full body is returned;
+//   - with a satisfiable range ("bytes=start-end", "bytes=start-" or "bytes=-suffixLength"), a
+//     206 response with the requested slice and the matching Content-Range header is returned;
+//   - with an unsatisfiable range, a 416 response with a "Content-Range: bytes */size" header is
+//     returned.
+//
+// Malformed and multi-range headers are ignored, as allowed by RFC 7233, giving a 200 response.
+// All responses carry the "Accept-Ranges: bytes" header and the given Content-Type.
+func NewRangeResponder(fullBody []byte, contentType string) Responder {
+	size := int64(len(fullBody))
+
+	return func(req *http.Request) (*http.Response, error) {
+		var response *http.Response
+
+		start, end, status := parseRange(req.Header.Get("Range"), size)
+		switch status {
+		case http.StatusPartialContent:
+			response = NewBytesResponse(status, fullBody[start:end+1])
+			response.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+		case http.StatusRequestedRangeNotSatisfiable:
+			response = NewStringResponse(status, "")
+			response.Header.Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+		default:
+			response = NewBytesResponse(http.StatusOK, fullBody)
+		}
+
+		response.Header.Set("Accept-Ranges", "bytes")
+		if contentType != "" {
+			response.Header.Set("Content-Type", contentType)
+		}
+		return response, nil
+	}
+}
+
+// parseRange parses the Range header value h for a body of the given size.  It returns the
+// inclusive bounds of the range and the status code to respond with.
+func parseRange(h string, size int64) (start, end int64, status int) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(h, prefix) || strings.Contains(h, ",") {
+		return 0, 0, http.StatusOK
+	}
+
+	bounds := strings.SplitN(strings.TrimSpace(h[len(prefix):]), "-", 2)
+	if len(bounds) != 2 {
+		return 0, 0, http.StatusOK
+	}
+
+	if bounds[0] == "" {
+		// suffix range: the last n bytes
+		n, err := strconv.ParseInt(bounds[1], 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, http.StatusOK
+		}
+		if n == 0 || size == 0 {
+			return 0, 0, http.StatusRequestedRangeNotSatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, http.StatusPartialContent
+	}
+
+	start, err := strconv.ParseInt(bounds[0], 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, http.StatusOK
+	}
+
+	end = size - 1
+	if bounds[1] != "" {
+		end, err = strconv.ParseInt(bounds[1], 10, 64)
+		if err != nil || end < start {
+			return 0, 0, http.StatusOK
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+
+	if start >= size {
+		return 0, 0, http.StatusRequestedRangeNotSatisfiable
+	}
+	return start, end, http.StatusPartialContent
+}
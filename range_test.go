@@ -0,0 +1,58 @@
+package httpmock
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestNewRangeResponder(t *testing.T) {
+	responder := NewRangeResponder([]byte("hello world"), "text/plain")
+
+	tests := []struct {
+		rangeHeader  string
+		status       int
+		body         string
+		contentRange string
+	}{
+		{"", 200, "hello world", ""},
+		{"bytes=0-4", 206, "hello", "bytes 0-4/11"},
+		{"bytes=6-", 206, "world", "bytes 6-10/11"},
+		{"bytes=-5", 206, "world", "bytes 6-10/11"},
+		{"bytes=6-100", 206, "world", "bytes 6-10/11"},
+		{"bytes=11-20", 416, "", "bytes */11"},
+		{"bytes=0-1,3-4", 200, "hello world", ""},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", testUrl, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.rangeHeader != "" {
+			req.Header.Set("Range", test.rangeHeader)
+		}
+
+		response, err := responder(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.StatusCode != test.status {
+			t.Fatalf("Range %q: expected status %d, got %d", test.rangeHeader, test.status, response.StatusCode)
+		}
+
+		if v := response.Header.Get("Content-Range"); v != test.contentRange {
+			t.Fatalf("Range %q: expected Content-Range %q, got %q", test.rangeHeader, test.contentRange, v)
+		}
+
+		data, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != test.body {
+			t.Fatalf("Range %q: expected body %q, got %q", test.rangeHeader, test.body, data)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package httpmock
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// recordedRequest is a copy of a request routed to a registered responder, with its body
+// buffered.
+type recordedRequest struct {
+	req  *http.Request
+	body []byte
+}
+
+// snapshotRequest buffers the body of req, if buffer is true, and returns a copy of it, to be
+// recorded once the responder handling it is known.  If buffer is false, the copy has no body.
+func snapshotRequest(req *http.Request, buffer bool) (recordedRequest, error) {
+	if !buffer {
+		c := req.Clone(req.Context())
+		c.Body = nil
+		return recordedRequest{req: c}, nil
+	}
+
+	body, err := ReadBody(req)
+	if err != nil {
+		return recordedRequest{}, err
+	}
+	return recordedRequest{req: req.Clone(req.Context()), body: body}, nil
+}
+
+// storeRequest keeps rec as the most recent request routed to the responder registered for key.
+func (m *MockTransport) storeRequest(key string, rec recordedRequest) {
+	m.mu.Lock()
+	if m.lastRequests == nil {
+		m.lastRequests = make(map[string]recordedRequest)
+	}
+	m.lastRequests[key] = rec
+	m.mu.Unlock()
+}
+
+// lastRequest returns the most recent request routed to the responder registered for the given
+// HTTP method and URL.
+func (m *MockTransport) lastRequest(method, url string) (recordedRequest, error) {
+	m.mu.RLock()
+	rec, ok := m.lastRequests[responderKey(method, url)]
+	m.mu.RUnlock()
+
+	if !ok {
+		return recordedRequest{}, fmt.Errorf("no request recorded for %s %s", method, url)
+	}
+	return rec, nil
+}
+
+// RequestBody returns a copy of the body of the most recent request routed to the responder
+// registered for the given HTTP method and URL, or an error if no such request was recorded.  It
+// requires BufferRequestBodies, the body is empty otherwise.
+func (m *MockTransport) RequestBody(method, url string) ([]byte, error) {
+	rec, err := m.lastRequest(method, url)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), rec.body...), nil
+}
+
+// RequestBody returns the body of the most recent request recorded by DefaultTransport for the
+// given HTTP method and URL.
+func RequestBody(method, url string) ([]byte, error) {
+	return DefaultTransport.RequestBody(method, url)
+}
+
+// AssertBodyMatched returns an error unless the body of the most recent request routed to the
+// responder registered for the given HTTP method and URL satisfies match, e.g. to check after the
+// fact that the right payload was sent.  It fails as well if no such request was recorded.  The
+// bodies are only recorded if BufferRequestBodies is true.
+func (m *MockTransport) AssertBodyMatched(method, url string, match func([]byte) bool) error {
+	rec, err := m.lastRequest(method, url)
+	if err != nil {
+		return err
+	}
+
+	if !match(append([]byte(nil), rec.body...)) {
+		return fmt.Errorf("body of the last %s %s request doesn't match: %q", method, url, rec.body)
+	}
+	return nil
+}
+
+// AssertBodyMatched checks the body of the most recent request recorded by DefaultTransport for
+// the given HTTP method and URL, see MockTransport.AssertBodyMatched.
+func AssertBodyMatched(method, url string, match func([]byte) bool) error {
+	return DefaultTransport.AssertBodyMatched(method, url, match)
+}
+
+// AssertRequestHeader returns an error unless the most recent request routed to the responder
+// registered for the given HTTP method and URL had the header headerKey set to expectedValue,
+// e.g. to check the Authorization or Content-Type sent.  Only the first value of the header is
+// compared.  It fails as well if the header is absent or if no such request was recorded.
+func (m *MockTransport) AssertRequestHeader(method, url, headerKey, expectedValue string) error {
+	rec, err := m.lastRequest(method, url)
+	if err != nil {
+		return err
+	}
+
+	values := rec.req.Header.Values(headerKey)
+	if len(values) == 0 {
+		return fmt.Errorf("header %s of the last %s %s request is absent, expected %q",
+			headerKey, method, url, expectedValue)
+	}
+	if values[0] != expectedValue {
+		return fmt.Errorf("header %s of the last %s %s request is %q, expected %q",
+			headerKey, method, url, values[0], expectedValue)
+	}
+	return nil
+}
+
+// AssertRequestHeader checks a header of the most recent request recorded by DefaultTransport for
+// the given HTTP method and URL, see MockTransport.AssertRequestHeader.
+func AssertRequestHeader(method, url, headerKey, expectedValue string) error {
+	return DefaultTransport.AssertRequestHeader(method, url, headerKey, expectedValue)
+}
+
+// storeUnmatchedRequest keeps rec as a request which didn't match any responder.
+func (m *MockTransport) storeUnmatchedRequest(rec recordedRequest) {
+	m.mu.Lock()
+	m.unmatched = append(m.unmatched, rec)
+	m.mu.Unlock()
+}
+
+// UnmatchedRequests returns a copy of every request which didn't match any responder and was
+// thus handed to the 'no responder' responder, in the order they were received.  Each request
+// comes with its own reader over the buffered body, which is empty unless BufferRequestBodies
+// is true.
+func (m *MockTransport) UnmatchedRequests() []*http.Request {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	reqs := make([]*http.Request, len(m.unmatched))
+	for i, rec := range m.unmatched {
+		req := rec.req.Clone(rec.req.Context())
+		if rec.body != nil {
+			req.Body = ioutil.NopCloser(bytes.NewReader(rec.body))
+		} else {
+			req.Body = http.NoBody
+		}
+		reqs[i] = req
+	}
+	return reqs
+}
+
+// UnmatchedRequests returns the requests DefaultTransport couldn't match, see
+// MockTransport.UnmatchedRequests.
+func UnmatchedRequests() []*http.Request {
+	return DefaultTransport.UnmatchedRequests()
+}
@@ -0,0 +1,218 @@
+package httpmock
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMockTransportRequestBody(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+	DefaultTransport.BufferRequestBodies = true
+	defer func() { DefaultTransport.BufferRequestBodies = false }()
+
+	if _, err := RequestBody("POST", testUrl); err == nil {
+		t.Fatal("expected an error as no request was recorded")
+	}
+
+	RegisterResponder("POST", testUrl, func(req *http.Request) (*http.Response, error) {
+		// the responder can still read the body
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return NewStringResponse(200, string(data)), nil
+	})
+
+	for _, body := range []string{"first", "second"} {
+		if _, err := http.Post(testUrl, "text/plain", strings.NewReader(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	body, err := RequestBody("POST", testUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != "second" {
+		t.Fatalf("expected the most recent body, got %q", body)
+	}
+
+	// the returned slice is a copy
+	body[0] = 'X'
+	if body, _ := RequestBody("POST", testUrl); string(body) != "second" {
+		t.Fatalf("expected the recorded body to be left untouched, got %q", body)
+	}
+}
+
+func TestMockTransportUnmatchedRequests(t *testing.T) {
+	Reset()
+	Activate()
+	defer DeactivateAndReset()
+	DefaultTransport.BufferRequestBodies = true
+	defer func() { DefaultTransport.BufferRequestBodies = false }()
+
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world"))
+
+	if _, err := http.Get(testUrl); err != nil {
+		t.Fatal(err)
+	}
+	http.Get(testUrl + "typo")
+	http.Post(testUrl, "text/plain", strings.NewReader("hello"))
+
+	unmatched := UnmatchedRequests()
+	if len(unmatched) != 2 {
+		t.Fatalf("expected 2 unmatched requests, got %d", len(unmatched))
+	}
+
+	if unmatched[0].Method != "GET" || unmatched[0].URL.String() != testUrl+"typo" {
+		t.Fatalf("unexpected first unmatched request %s %s", unmatched[0].Method, unmatched[0].URL)
+	}
+
+	if unmatched[1].Method != "POST" || unmatched[1].URL.String() != testUrl {
+		t.Fatalf("unexpected second unmatched request %s %s", unmatched[1].Method, unmatched[1].URL)
+	}
+
+	body, err := ioutil.ReadAll(unmatched[1].Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != "hello" {
+		t.Fatalf("expected the buffered body, got %q", body)
+	}
+
+	Reset()
+
+	if n := len(UnmatchedRequests()); n != 0 {
+		t.Fatalf("expected Reset to clear unmatched requests, got %d", n)
+	}
+}
+
+// streamingBody is a request body which reports whether it was read.
+type streamingBody struct {
+	io.Reader
+	read bool
+}
+
+func (s *streamingBody) Read(p []byte) (int, error) {
+	s.read = true
+	return s.Reader.Read(p)
+}
+
+func (s *streamingBody) Close() error {
+	return nil
+}
+
+func TestMockTransportBufferRequestBodiesOff(t *testing.T) {
+	// the bodies aren't buffered by default
+	mock := NewMockTransport()
+
+	body := &streamingBody{Reader: strings.NewReader("upload")}
+
+	mock.RegisterResponder("POST", testUrl, func(req *http.Request) (*http.Response, error) {
+		if req.Body != body || body.read {
+			t.Error("expected the untouched body")
+		}
+		return NewStringResponse(200, ""), nil
+	})
+
+	req, err := http.NewRequest("POST", testUrl, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := mock.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := mock.RequestBody("POST", testUrl); err != nil || len(data) != 0 {
+		t.Fatalf("expected no recorded body, got %q, %v", data, err)
+	}
+}
+
+func TestMockTransportTotalRequestBytes(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("POST", testUrl, NewStringResponder(200, "ok"))
+
+	// matched and unmatched requests count alike
+	for _, u := range []string{testUrl, testUrl, testUrl + "unknown"} {
+		http.Post(u, "text/plain", strings.NewReader("0123456789"))
+	}
+	if _, err := http.Get(testUrl); err == nil {
+		t.Fatal("expected no responder for GET")
+	}
+
+	if total := TotalRequestBytes(); total != 30 {
+		t.Fatalf("expected 30 bytes, got %d", total)
+	}
+
+	Reset()
+	if total := TotalRequestBytes(); total != 0 {
+		t.Fatalf("expected Reset to clear the total, got %d", total)
+	}
+}
+
+func TestMockTransportAssertBodyMatched(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+	DefaultTransport.BufferRequestBodies = true
+	defer func() { DefaultTransport.BufferRequestBodies = false }()
+
+	RegisterResponder("POST", testUrl, NewStringResponder(201, "created"))
+
+	isArticle := func(body []byte) bool {
+		return strings.Contains(string(body), `"title"`)
+	}
+
+	if err := AssertBodyMatched("POST", testUrl, isArticle); err == nil {
+		t.Fatal("expected an error before any request")
+	}
+
+	http.Post(testUrl, "application/json", strings.NewReader(`{"title":"My Great Article"}`))
+	if err := AssertBodyMatched("POST", testUrl, isArticle); err != nil {
+		t.Fatal(err)
+	}
+
+	http.Post(testUrl, "application/json", strings.NewReader(`{"name":"wrong"}`))
+	err := AssertBodyMatched("POST", testUrl, isArticle)
+	if err == nil || !strings.Contains(err.Error(), `{\"name\":\"wrong\"}`) {
+		t.Fatalf("expected an error showing the body, got %v", err)
+	}
+}
+
+func TestMockTransportAssertRequestHeader(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("POST", testUrl, NewStringResponder(201, "created"))
+
+	if err := AssertRequestHeader("POST", testUrl, "Content-Type", "application/json"); err == nil {
+		t.Fatal("expected an error before any request")
+	}
+
+	http.Post(testUrl, "application/json", strings.NewReader(`{"title":"My Great Article"}`))
+
+	// present and matching
+	if err := AssertRequestHeader("POST", testUrl, "content-type", "application/json"); err != nil {
+		t.Fatal(err)
+	}
+
+	// present but mismatching
+	err := AssertRequestHeader("POST", testUrl, "Content-Type", "text/plain")
+	if err == nil || !strings.Contains(err.Error(), `"application/json"`) {
+		t.Fatalf("expected an error showing the actual value, got %v", err)
+	}
+
+	// absent
+	err = AssertRequestHeader("POST", testUrl, "Authorization", "Bearer token")
+	if err == nil || !strings.Contains(err.Error(), "absent") {
+		t.Fatalf("expected an error about the absent header, got %v", err)
+	}
+}
@@ -0,0 +1,413 @@
+package httpmock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Delay returns a Responder which waits for d before calling r.  If the request context is
+// cancelled while waiting, the context error is returned and r is not called.  As http.Client
+// cancels the request context when its Timeout expires, a client timeout shorter than d makes
+// the call return promptly with a timeout error.
+func (r Responder) Delay(d time.Duration) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		if err := sleepContext(req, d); err != nil {
+			return nil, err
+		}
+		return r(req)
+	}
+}
+
+// DelayJitter returns a Responder which waits a random duration in [base, base+jitter) before
+// calling r, to simulate realistic latency variance.  An optional seed makes the sequence of
+// durations reproducible; otherwise the RNG is seeded from the current time.  As with Delay,
+// cancelling the request context short-circuits the wait.
+func (r Responder) DelayJitter(base, jitter time.Duration, seed ...int64) Responder {
+	rnd := newRand(seed)
+	var mu sync.Mutex
+
+	return func(req *http.Request) (*http.Response, error) {
+		d := base
+		if jitter > 0 {
+			mu.Lock()
+			d += time.Duration(rnd.Int63n(int64(jitter)))
+			mu.Unlock()
+		}
+
+		if err := sleepContext(req, d); err != nil {
+			return nil, err
+		}
+		return r(req)
+	}
+}
+
+// ActiveBetween returns a Responder which calls r only when the current time, as given by
+// MockTransport.Now, is in [start, end).  Outside of this window, it behaves as if it wasn't
+// registered: the request falls through to the next matching responder or, failing that, to the
+// 'no responder' responder, as for the Responders created by Times.  Used outside of a
+// MockTransport, the requests out of the window fail with NoResponderFound.
+func (r Responder) ActiveBetween(start, end time.Time) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		t := now(req)
+		if t.Before(start) || !t.Before(end) {
+			if transportFromRequest(req) == nil {
+				return ConnectionFailure(req)
+			}
+			return nil, errInactive
+		}
+		return r(req)
+	}
+}
+
+// WithHeader returns a Responder which merges h into the headers of the responses of r.  The
+// values of h replace the ones of the response for the same key, except for Set-Cookie whose
+// values are added, so that every cookie gets its own header line.
+func (r Responder) WithHeader(h http.Header) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := r(req)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+
+		resp = cloneResponse(resp)
+		for key, values := range h {
+			if http.CanonicalHeaderKey(key) != "Set-Cookie" {
+				resp.Header.Del(key)
+			}
+			for _, v := range values {
+				resp.Header.Add(key, v)
+			}
+		}
+		return resp, nil
+	}
+}
+
+// WithRawHeader returns a Responder which sets the header key of the responses of r to value,
+// writing directly to the Header map so that key keeps its exact casing, e.g.
+// "x-ratelimit-remaining", as some servers send.  This bypasses the canonicalization of
+// http.Header: Header.Get and Header.Set don't see such a key, only a direct map read with the
+// same casing does, which is what this is meant to test.  A canonical header with the same name
+// set by r is left untouched.
+func (r Responder) WithRawHeader(key, value string) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := r(req)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+
+		resp = cloneResponse(resp)
+		resp.Header[key] = []string{value}
+		return resp, nil
+	}
+}
+
+// WithDate returns a Responder which sets the Date header of the responses of r to the current
+// time, as given by MockTransport.Now, in the format of RFC 1123 (see http.TimeFormat), like real
+// servers do.  This is useful to test HTTP cache freshness calculations.  A Date header already
+// set by r is left untouched.
+func (r Responder) WithDate() Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := r(req)
+		if err != nil || resp == nil || resp.Header.Get("Date") != "" {
+			return resp, err
+		}
+
+		resp = cloneResponse(resp)
+		resp.Header.Set("Date", now(req).UTC().Format(http.TimeFormat))
+		return resp, nil
+	}
+}
+
+// AsHTTP10 returns a Responder whose responses look like they come from an HTTP/1.0 server
+// without keep-alive: Proto is "HTTP/1.0" and Close is true.
+func (r Responder) AsHTTP10() Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := r(req)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+
+		resp = cloneResponse(resp)
+		resp.Proto = "HTTP/1.0"
+		resp.ProtoMajor = 1
+		resp.ProtoMinor = 0
+		resp.Close = true
+		return resp, nil
+	}
+}
+
+// ThrottleBandwidth returns a Responder whose response bodies are read at most at bytesPerSecond,
+// as through a slow link, to test download progress reporting or timeouts with large bodies.  The
+// reads return at most a tenth of a second worth of bytes, then wait for the time it took to
+// transfer them, using the clock of the transport (see MockTransport.Sleep).  If the request
+// context is cancelled, the pending and later reads fail with the context error.  A
+// bytesPerSecond lower than or equal to 0 disables the throttling.
+func (r Responder) ThrottleBandwidth(bytesPerSecond int) Responder {
+	if bytesPerSecond <= 0 {
+		return r
+	}
+
+	chunk := bytesPerSecond / 10
+	if chunk == 0 {
+		chunk = 1
+	}
+
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := r(req)
+		if err != nil || resp == nil || resp.Body == nil {
+			return resp, err
+		}
+
+		resp = cloneResponse(resp)
+		resp.Body = &throttledBody{
+			body:           resp.Body,
+			req:            req,
+			bytesPerSecond: int64(bytesPerSecond),
+			chunk:          chunk,
+		}
+		return resp, nil
+	}
+}
+
+// throttledBody paces the reads of body, see Responder.ThrottleBandwidth.
+type throttledBody struct {
+	body           io.ReadCloser
+	req            *http.Request
+	bytesPerSecond int64
+	chunk          int
+
+	// read is the number of bytes read so far and waited the time of
+	// read*time.Second/bytesPerSecond, so that the rounding errors don't add up.
+	read   int64
+	waited time.Duration
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if err := b.req.Context().Err(); err != nil {
+		return 0, err
+	}
+
+	if len(p) > b.chunk {
+		p = p[:b.chunk]
+	}
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.read += int64(n)
+		d := time.Duration(b.read*int64(time.Second)/b.bytesPerSecond) - b.waited
+		b.waited += d
+		if serr := sleepContext(b.req, d); serr != nil {
+			return 0, serr
+		}
+	}
+	return n, err
+}
+
+func (b *throttledBody) Close() error {
+	return b.body.Close()
+}
+
+// WithProto2 returns a Responder whose responses look like they come from an HTTP/2 server:
+// Proto is "HTTP/2.0", as set by net/http, and ProtoMajor is 2, so that the code checking for
+// HTTP/2 takes its HTTP/2 paths.  Only the protocol fields change: a RoundTripper can't push
+// streams, so HTTP/2 server push can't be mocked.
+func (r Responder) WithProto2() Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := r(req)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+
+		resp = cloneResponse(resp)
+		resp.Proto = "HTTP/2.0"
+		resp.ProtoMajor = 2
+		resp.ProtoMinor = 0
+		return resp, nil
+	}
+}
+
+// Log returns a Responder which logs each request handled by r through t.Logf, with its method,
+// URL and the status of the response, or the error returned by r.  Neither the request nor the
+// response body is read.
+func (r Responder) Log(t testing.TB) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		resp, err := r(req)
+		switch {
+		case fallsThrough(err):
+			// not handled by r, the request falls through
+		case err != nil:
+			t.Logf("httpmock: %s %s: %s", req.Method, req.URL, err)
+		case resp != nil:
+			t.Logf("httpmock: %s %s: %d", req.Method, req.URL, resp.StatusCode)
+		}
+		return resp, err
+	}
+}
+
+// errExhausted is returned by the Responders created by Times once used up.  MockTransport
+// handles it by letting the request fall through to the next matching responder.
+var errExhausted = errors.New("httpmock: responder called more times than allowed")
+
+// errInactive is returned by the Responders created by ActiveBetween outside of their window.
+// MockTransport handles it as errExhausted.
+var errInactive = errors.New("httpmock: responder called outside of its active window")
+
+// fallsThrough returns true if err, returned by a responder, lets the request fall through to the
+// next matching responder.
+func fallsThrough(err error) bool {
+	return errors.Is(err, errExhausted) || errors.Is(err, errInactive)
+}
+
+// Times returns a Responder which calls r for the first n calls only.  Afterwards it behaves as
+// if it wasn't registered: the request falls through to the next matching responder (a prefix
+// or matcher responder, see RegisterMatcherResponder) or, failing that, to the 'no responder'
+// responder.  Calls falling through are not counted for r.  Once used up, r isn't reported by
+// MockTransport.Match either.  Used outside of a MockTransport, the extra calls fail with an
+// error.
+func (r Responder) Times(n int) Responder {
+	var calls int64
+	return func(req *http.Request) (*http.Response, error) {
+		c := atomic.AddInt64(&calls, 1)
+		if c >= int64(n) {
+			if usedUp, ok := req.Context().Value(usedUpKey{}).(*int32); ok {
+				atomic.StoreInt32(usedUp, 1)
+			}
+		}
+		if c > int64(n) {
+			return nil, errExhausted
+		}
+		return r(req)
+	}
+}
+
+// WeightedResponder is a Responder with its weight, see NewWeightedResponder.
+type WeightedResponder struct {
+	Responder Responder
+	Weight    int
+}
+
+// NewWeightedResponder returns a Responder which, on each call, picks one of the weighted
+// responders with a probability proportional to its weight.  For instance weights of 70 and 30
+// give 70% and 30% of the calls respectively.  An optional seed makes the picks reproducible.
+//
+// It panics if a weight is negative or if the weights don't sum to a positive number.
+func NewWeightedResponder(weighted []WeightedResponder, seed ...int64) Responder {
+	total := 0
+	for _, w := range weighted {
+		if w.Weight < 0 {
+			panic(fmt.Sprintf("httpmock: negative weight %d", w.Weight))
+		}
+		total += w.Weight
+	}
+	if total <= 0 {
+		panic("httpmock: weights must sum to a positive number")
+	}
+
+	rnd := newRand(seed)
+	var mu sync.Mutex
+
+	return func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		n := rnd.Intn(total)
+		mu.Unlock()
+
+		for _, w := range weighted {
+			if n < w.Weight {
+				return w.Responder(req)
+			}
+			n -= w.Weight
+		}
+		panic("unreachable")
+	}
+}
+
+// randSource is the source set by SetRandSource, guarded by randMu.
+var randSource rand.Source
+var randMu sync.Mutex
+
+// SetRandSource makes the random responders created afterwards without an explicit seed (see
+// DelayJitter and NewWeightedResponder), as well as the multipart boundaries (see
+// NewMultipartResponse), draw from src instead of a time-seeded source.  Each of them is seeded
+// from src when created, so with a source seeded with a fixed value the output is reproducible
+// as long as they are created in the same order.  Passing nil restores time-seeded sources.
+func SetRandSource(src rand.Source) {
+	randMu.Lock()
+	randSource = src
+	randMu.Unlock()
+}
+
+// newRand returns a new RNG seeded with the first seed if any, from the source set by
+// SetRandSource if any, or with the current time.
+func newRand(seed []int64) *rand.Rand {
+	if len(seed) > 0 {
+		return rand.New(rand.NewSource(seed[0]))
+	}
+
+	randMu.Lock()
+	defer randMu.Unlock()
+	if randSource != nil {
+		return rand.New(rand.NewSource(randSource.Int63()))
+	}
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// StreamContext returns a context for a responder streaming its response body, e.g. from a
+// goroutine or with waits between chunks, so it stops when the mocks go away.  The context is
+// done when the request context is, when the MockTransport req went through is Reset, or, for
+// DefaultTransport, when the mocks are deactivated (see Deactivate).  Cancelling the context
+// only affects the streams running at that time: later requests get new contexts.
+//
+// The returned CancelFunc must be called once the stream ends, typically when the response body
+// is closed, to release the resources.  See NewSSEResponder.
+func StreamContext(req *http.Request) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(req.Context())
+
+	m := transportFromRequest(req)
+	if m == nil {
+		return ctx, cancel
+	}
+
+	// the goroutine ends with ctx, so at the latest when the returned CancelFunc is called
+	streams := m.streamContext()
+	go func() {
+		select {
+		case <-streams.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// now returns the current time according to the transport req went through, see MockTransport.Now.
+func now(req *http.Request) time.Time {
+	if m := transportFromRequest(req); m != nil && m.Now != nil {
+		return m.Now()
+	}
+	return time.Now()
+}
+
+// sleepContext waits for d or until the request context is done, whichever comes first.  The
+// Sleep hook of the transport req went through is used if any, see MockTransport.Sleep.
+func sleepContext(req *http.Request, d time.Duration) error {
+	if m := transportFromRequest(req); m != nil && m.Sleep != nil {
+		return m.Sleep(req.Context(), d)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-req.Context().Done():
+		return req.Context().Err()
+	}
+}
@@ -0,0 +1,585 @@
+package httpmock
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResponderDelay(t *testing.T) {
+	responder := NewStringResponder(200, "hello world").Delay(20 * time.Millisecond)
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := responder(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("expected a delay of at least 20ms, got %s", elapsed)
+	}
+}
+
+func TestResponderDelayJitter(t *testing.T) {
+	base, jitter := 5*time.Millisecond, 10*time.Millisecond
+	responder := NewStringResponder(200, "hello world").DelayJitter(base, jitter, 42)
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		start := time.Now()
+		if _, err := responder(req); err != nil {
+			t.Fatal(err)
+		}
+
+		if elapsed := time.Since(start); elapsed < base {
+			t.Fatalf("expected a delay of at least %s, got %s", base, elapsed)
+		}
+	}
+
+	// cancelling the context short-circuits the sleep
+	responder = NewStringResponder(200, "hello world").DelayJitter(time.Minute, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	start := time.Now()
+	if _, err := responder(req.WithContext(ctx)); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the cancellation to be immediate, took %s", elapsed)
+	}
+}
+
+func TestNewWeightedResponder(t *testing.T) {
+	responder := NewWeightedResponder([]WeightedResponder{
+		{Responder: NewStringResponder(200, "ok"), Weight: 70},
+		{Responder: NewStringResponder(500, "ko"), Weight: 30},
+	}, 1)
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const calls = 10000
+	counts := make(map[int]int)
+	for i := 0; i < calls; i++ {
+		resp, err := responder(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[resp.StatusCode]++
+	}
+
+	for status, expected := range map[int]float64{200: 0.7, 500: 0.3} {
+		got := float64(counts[status]) / calls
+		if got < expected-0.03 || got > expected+0.03 {
+			t.Fatalf("status %d: expected a ratio of about %.2f, got %.3f", status, expected, got)
+		}
+	}
+}
+
+func TestNewWeightedResponderInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for weights summing to 0")
+		}
+	}()
+	NewWeightedResponder([]WeightedResponder{{Responder: NewStringResponder(200, "ok")}})
+}
+
+// fakeClock is a clock whose time only moves when sleeping.
+type fakeClock struct {
+	mu      sync.Mutex
+	current time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.current
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.current = c.current.Add(d)
+	return ctx.Err()
+}
+
+func (c *fakeClock) install(m *MockTransport) {
+	m.Now = c.Now
+	m.Sleep = c.Sleep
+}
+
+func TestMockTransportFakeClock(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2016, 12, 10, 0, 0, 0, 0, time.UTC)}
+
+	mock := NewMockTransport()
+	clock.install(mock)
+	mock.RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world").Delay(time.Hour))
+
+	client := &http.Client{Transport: mock}
+
+	start := time.Now()
+	if _, err := client.Get(testUrl); err != nil {
+		t.Fatal(err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the fake clock not to really sleep, took %s", elapsed)
+	}
+
+	if expected := time.Date(2016, 12, 10, 1, 0, 0, 0, time.UTC); !clock.Now().Equal(expected) {
+		t.Fatalf("expected the fake clock to be advanced to %s, got %s", expected, clock.Now())
+	}
+}
+
+func TestResponderWithHeaderCookies(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	h := http.Header{}
+	h.Add("Set-Cookie", "a=1")
+	h.Add("Set-Cookie", "b=2")
+	h.Add("Set-Cookie", "c=3")
+	h.Set("X-Custom", "new")
+
+	base := NewStringResponse(200, "hello world")
+	base.Header.Set("X-Custom", "old")
+
+	RegisterResponder("GET", testUrl, ResponderFromResponse(base).WithHeader(h))
+
+	// twice, to make sure cookies don't pile up on the registered response
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(testUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := len(resp.Cookies()); n != 3 {
+			t.Fatalf("expected 3 cookies, got %d", n)
+		}
+
+		if v := resp.Header["X-Custom"]; len(v) != 1 || v[0] != "new" {
+			t.Fatalf("expected X-Custom to be replaced, got %v", v)
+		}
+	}
+}
+
+func TestResponderActiveBetween(t *testing.T) {
+	start := time.Date(2016, 12, 10, 2, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	clock := &fakeClock{current: start.Add(-time.Minute)}
+
+	mock := NewMockTransport()
+	clock.install(mock)
+	mock.RegisterResponder("GET", testUrl, NewStringResponder(200, "maintenance").ActiveBetween(start, end))
+
+	client := &http.Client{Transport: mock}
+
+	for _, step := range []struct {
+		advance time.Duration
+		active  bool
+	}{
+		{0, false},
+		{time.Minute, true},
+		{59 * time.Minute, true},
+		{time.Minute, false},
+	} {
+		clock.Sleep(context.Background(), step.advance)
+
+		_, err := client.Get(testUrl)
+		if step.active && err != nil {
+			t.Fatalf("at %s: expected the responder to be active, got %v", clock.Now(), err)
+		}
+		if !step.active && (err == nil || !strings.Contains(err.Error(), NoResponderFound.Error())) {
+			t.Fatalf("at %s: expected NoResponderFound, got %v", clock.Now(), err)
+		}
+	}
+}
+
+func TestResponderActiveBetweenFallThrough(t *testing.T) {
+	start := time.Date(2016, 12, 10, 2, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	clock := &fakeClock{current: end}
+
+	mock := NewMockTransport()
+	clock.install(mock)
+	mock.RegisterResponder("GET", testUrl+"api/articles",
+		NewStringResponder(503, "maintenance").ActiveBetween(start, end))
+	mock.RegisterPrefixResponder("GET", testUrl+"api/", NewStringResponder(200, "prefix"))
+	mock.RegisterResponder("GET", testUrl+"users",
+		NewStringResponder(503, "maintenance").ActiveBetween(start, end))
+	mock.RegisterNoResponder(NewStringResponder(404, "not found"))
+
+	client := &http.Client{Transport: mock}
+
+	// outside of the window, the requests fall through to the prefix responder
+	resp, err := client.Get(testUrl + "api/articles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected the prefix responder, got status %d", resp.StatusCode)
+	}
+
+	// or to the no responder
+	if resp, err = client.Get(testUrl + "users"); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 404 {
+		t.Fatalf("expected the no responder, got status %d", resp.StatusCode)
+	}
+	if n := len(mock.UnmatchedRequests()); n != 1 {
+		t.Fatalf("expected 1 unmatched request, got %d", n)
+	}
+	if count := mock.GetCallCountInfo()["GET "+testUrl+"users"]; count != 0 {
+		t.Fatalf("expected the inactive responder not to be counted, got %d", count)
+	}
+
+	// the window isn't over for good
+	clock.Sleep(context.Background(), -time.Hour)
+	if resp, err = client.Get(testUrl + "users"); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 503 {
+		t.Fatalf("expected the responder to be active again, got status %d", resp.StatusCode)
+	}
+}
+
+func TestResponderAsHTTP10(t *testing.T) {
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := NewStringResponder(200, "hello world").AsHTTP10()(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Proto != "HTTP/1.0" || resp.ProtoMajor != 1 || resp.ProtoMinor != 0 {
+		t.Fatalf("expected HTTP/1.0, got %s (%d.%d)", resp.Proto, resp.ProtoMajor, resp.ProtoMinor)
+	}
+
+	if !resp.Close {
+		t.Fatal("expected Close to be true")
+	}
+}
+
+func TestResponderDelayClientTimeout(t *testing.T) {
+	mock := NewMockTransport()
+	mock.RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world").Delay(5*time.Second))
+
+	client := &http.Client{Transport: mock, Timeout: 50 * time.Millisecond}
+
+	start := time.Now()
+	_, err := client.Get(testUrl)
+	elapsed := time.Since(start)
+
+	if err == nil {
+		t.Fatal("expected a timeout error")
+	}
+
+	if uerr, ok := err.(*url.Error); !ok || !uerr.Timeout() {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+
+	if elapsed > time.Second {
+		t.Fatalf("expected the client timeout to abort the delay, took %s", elapsed)
+	}
+}
+
+func TestResponderTimes(t *testing.T) {
+	Reset()
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "first").Times(2))
+	RegisterMatcherResponder(func(req *http.Request) bool {
+		return req.URL.Path == "/"
+	}, NewStringResponder(200, "other"))
+
+	for i, expected := range []string{"first", "first", "other", "other"} {
+		resp, err := http.Get(testUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != expected {
+			t.Fatalf("call #%d: expected body %q, got %q", i+1, expected, data)
+		}
+	}
+
+	// calls falling through aren't counted for the exhausted responder
+	if count := GetCallCountInfo()["GET "+testUrl]; count != 2 {
+		t.Fatalf("expected 2 calls, got %d", count)
+	}
+	if total := GetTotalCallCount(); total != 4 {
+		t.Fatalf("expected a total of 4 calls, got %d", total)
+	}
+}
+
+func TestResponderTimesNoResponder(t *testing.T) {
+	Reset()
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "once").Times(1))
+
+	if _, err := http.Get(testUrl); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := http.Get(testUrl)
+	if err == nil || !strings.Contains(err.Error(), NoResponderFound.Error()) {
+		t.Fatalf("expected the 2nd call to fall through to the no responder, got %v", err)
+	}
+
+	if n := len(UnmatchedRequests()); n != 1 {
+		t.Fatalf("expected 1 unmatched request, got %d", n)
+	}
+}
+
+func TestSetRandSource(t *testing.T) {
+	defer SetRandSource(nil)
+
+	run := func() string {
+		SetRandSource(rand.NewSource(42))
+
+		responder := NewWeightedResponder([]WeightedResponder{
+			{Responder: NewStringResponder(200, "a"), Weight: 50},
+			{Responder: NewStringResponder(200, "b"), Weight: 50},
+		})
+
+		req, err := http.NewRequest("GET", testUrl, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		for i := 0; i < 20; i++ {
+			resp, err := responder(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := out.ReadFrom(resp.Body); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		resp, err := NewMultipartResponse(200, []Part{{Name: "field", Body: []byte("value")}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out.WriteString(resp.Header.Get("Content-Type"))
+		if _, err := out.ReadFrom(resp.Body); err != nil {
+			t.Fatal(err)
+		}
+		return out.String()
+	}
+
+	first, second := run(), run()
+	if first != second {
+		t.Fatalf("expected identical output with the same source, got:\n%s\nand:\n%s", first, second)
+	}
+}
+
+func TestResponderWithRawHeader(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl,
+		NewStringResponder(200, "hello").WithRawHeader("x-ratelimit-remaining", "42"))
+
+	resp, err := http.Get(testUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := resp.Header["x-ratelimit-remaining"]; len(v) != 1 || v[0] != "42" {
+		t.Fatalf("expected the raw header to survive, got %v", resp.Header)
+	}
+
+	if _, ok := resp.Header["X-Ratelimit-Remaining"]; ok {
+		t.Fatalf("expected no canonical header, got %v", resp.Header)
+	}
+}
+
+func TestResponderLog(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+	DefaultTransport.BufferRequestBodies = true
+	defer func() { DefaultTransport.BufferRequestBodies = false }()
+
+	tb := &fakeTB{}
+	RegisterResponder("POST", testUrl, NewStringResponder(201, "created").Log(tb))
+	RegisterResponder("GET", testUrl, Responder(ConnectionFailure).Log(tb))
+
+	resp, err := http.Post(testUrl, "text/plain", strings.NewReader("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	http.Get(testUrl)
+
+	expected := []string{
+		"httpmock: POST " + testUrl + ": 201",
+		"httpmock: GET " + testUrl + ": " + NoResponderFound.Error(),
+	}
+	if len(tb.logs) != len(expected) {
+		t.Fatalf("expected logs %q, got %q", expected, tb.logs)
+	}
+	for i := range expected {
+		if tb.logs[i] != expected[i] {
+			t.Fatalf("expected logs %q, got %q", expected, tb.logs)
+		}
+	}
+
+	// the request body was left for the recording
+	if body, err := RequestBody("POST", testUrl); err != nil || string(body) != "payload" {
+		t.Fatalf("expected the request body, got %q, %v", body, err)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil || string(data) != "created" {
+		t.Fatalf("expected the response body, got %q, %v", data, err)
+	}
+}
+
+func TestResponderWithDate(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2016, 12, 10, 8, 30, 5, 0, time.FixedZone("CET", 3600))}
+
+	mock := NewMockTransport()
+	clock.install(mock)
+	mock.RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world").WithDate())
+
+	header := http.Header{"Date": {"Mon, 02 Jan 2006 15:04:05 GMT"}}
+	mock.RegisterResponder("GET", testUrl+"dated",
+		NewStringResponder(200, "hello world").WithHeader(header).WithDate())
+
+	client := &http.Client{Transport: mock}
+
+	resp, err := client.Get(testUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if date := resp.Header.Get("Date"); date != "Sat, 10 Dec 2016 07:30:05 GMT" {
+		t.Fatalf("expected an RFC 1123 date in GMT, got %q", date)
+	}
+	if _, err := time.Parse(time.RFC1123, resp.Header.Get("Date")); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = client.Get(testUrl + "dated")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if date := resp.Header.Get("Date"); date != header.Get("Date") {
+		t.Fatalf("expected the Date set by the responder to be kept, got %q", date)
+	}
+}
+
+func TestResponderThrottleBandwidth(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2016, 12, 10, 0, 0, 0, 0, time.UTC)}
+
+	mock := NewMockTransport()
+	clock.install(mock)
+
+	body := strings.Repeat("x", 1000)
+	mock.RegisterResponder("GET", testUrl, NewStringResponder(200, body).ThrottleBandwidth(300))
+
+	client := &http.Client{Transport: mock}
+
+	start := clock.Now()
+	resp, err := client.Get(testUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != body {
+		t.Fatalf("expected the whole body, got %d bytes", len(data))
+	}
+
+	// 1000 bytes at 300 bytes per second
+	expected := 1000 * time.Second / 300
+	if elapsed := clock.Now().Sub(start); elapsed != expected {
+		t.Fatalf("expected reading the body to take %s, took %s", expected, elapsed)
+	}
+}
+
+func TestResponderThrottleBandwidthCancel(t *testing.T) {
+	mock := NewMockTransport()
+	mock.RegisterResponder("GET", testUrl, NewStringResponder(200, strings.Repeat("x", 1000)).ThrottleBandwidth(10))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := mock.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	start := time.Now()
+	if _, err := ioutil.ReadAll(resp.Body); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the read to stop on cancel, took %s", elapsed)
+	}
+}
+
+func TestResponderWithProto2(t *testing.T) {
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := NewStringResponder(200, "hello world").WithProto2()(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.Proto != "HTTP/2.0" || resp.ProtoMajor != 2 || resp.ProtoMinor != 0 {
+		t.Fatalf("expected HTTP/2.0, got %s (%d.%d)", resp.Proto, resp.ProtoMajor, resp.ProtoMinor)
+	}
+
+	if !resp.ProtoAtLeast(2, 0) {
+		t.Fatal("expected the response to be at least HTTP/2.0")
+	}
+}
@@ -1,31 +1,133 @@
 package httpmock
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"io"
+	"io/fs"
+	"io/ioutil"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// ResponderFromResponse wraps an *http.Response in a Responder
+// ResponderFromResponse wraps an *http.Response in a Responder.  HEAD requests get a copy of resp
+// without body, but with the Content-Length a GET request would get.
 func ResponderFromResponse(resp *http.Response) Responder {
 	return func(req *http.Request) (*http.Response, error) {
+		if req.Method == "HEAD" {
+			return headResponse(resp), nil
+		}
 		return resp, nil
 	}
 }
 
+// ResponderFromHandler wraps an http.Handler in a Responder, so that existing test handlers can be
+// reused as mocks.  For each request, h is served into a new httptest.ResponseRecorder whose
+// result is returned, so each response gets its own body.  The handler sees the request as sent
+// by the client, with http.NoBody as body when it has none.
+func ResponderFromHandler(h http.Handler) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		if req.Body == nil {
+			r := *req
+			r.Body = http.NoBody
+			req = &r
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Result(), nil
+	}
+}
+
+// headResponse returns a copy of resp suitable for a HEAD request: same status and headers, no
+// body and the Content-Length of the original body.
+func headResponse(resp *http.Response) *http.Response {
+	head := *cloneResponse(resp)
+
+	if head.ContentLength <= 0 {
+		if d, ok := resp.Body.(*dummyReadCloser); ok {
+			head.ContentLength = d.size()
+		}
+	}
+	if head.ContentLength >= 0 {
+		head.Header.Set("Content-Length", strconv.FormatInt(head.ContentLength, 10))
+	}
+
+	head.Body = http.NoBody
+	return &head
+}
+
+// cloneResponse returns a shallow copy of resp with its own copy of the headers, so the copy can be
+// altered without affecting resp.  The body is shared.
+func cloneResponse(resp *http.Response) *http.Response {
+	c := *resp
+	c.Header = make(http.Header, len(resp.Header))
+	for k, v := range resp.Header {
+		c.Header[k] = append([]string(nil), v...)
+	}
+	return &c
+}
+
+// Response is a plain description of a response, handy in test tables where a function value
+// per row is awkward:
+//
+//	tests := []struct {
+//		url      string
+//		response httpmock.Response
+//	}{
+//		{"https://api.mybiz.com/articles", httpmock.Response{Status: 200, Body: "[]"}},
+//		{"https://api.mybiz.com/users", httpmock.Response{Status: 404}},
+//	}
+type Response struct {
+	// Status is the status code of the response, 200 if 0.
+	Status  int
+	Body    string
+	Headers http.Header
+}
+
+// Responder returns a Responder serving r.  Headers is copied, so r can be altered afterwards
+// without affecting the responder.
+func (r Response) Responder() Responder {
+	response := NewStringResponse(r.Status, r.Body)
+	for k, v := range r.Headers {
+		response.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+	}
+	return ResponderFromResponse(response)
+}
+
 // NewStringResponse creates an *http.Response with a body based on the given string.  Also accepts
-// an http status code.
+// an http status code; a status of 0 means 200, as do all the response helpers of this package.
 func NewStringResponse(status int, body string) *http.Response {
+	status = defaultStatus(status)
 	return &http.Response{
-		Status:     strconv.Itoa(status),
-		StatusCode: status,
-		Body:       NewRespBodyFromString(body),
-		Header:     http.Header{},
+		Status:        strconv.Itoa(status),
+		StatusCode:    status,
+		Body:          NewRespBodyFromString(body),
+		Header:        http.Header{},
+		ContentLength: int64(len(body)),
+	}
+}
+
+// defaultStatus returns status, or 200 if status is the zero value.
+func defaultStatus(status int) int {
+	if status == 0 {
+		return http.StatusOK
 	}
+	return status
 }
 
 // NewStringResponder creates a Responder from a given body (as a string) and status code.
@@ -34,13 +136,15 @@ func NewStringResponder(status int, body string) Responder {
 }
 
 // NewBytesResponse creates an *http.Response with a body based on the given bytes.  Also accepts
-// an http status code.
+// an http status code, 0 meaning 200.
 func NewBytesResponse(status int, body []byte) *http.Response {
+	status = defaultStatus(status)
 	return &http.Response{
-		Status:     strconv.Itoa(status),
-		StatusCode: status,
-		Body:       NewRespBodyFromBytes(body),
-		Header:     http.Header{},
+		Status:        strconv.Itoa(status),
+		StatusCode:    status,
+		Body:          NewRespBodyFromBytes(body),
+		Header:        http.Header{},
+		ContentLength: int64(len(body)),
 	}
 }
 
@@ -71,6 +175,31 @@ func NewJsonResponder(status int, body interface{}) (Responder, error) {
 	return ResponderFromResponse(resp), nil
 }
 
+// NewAutoResponder creates a Responder from a given body (as a string) and status code, with a
+// Content-Type header guessed from the body:
+//   - application/json if the body is valid JSON, leading and trailing whitespace aside;
+//   - application/xml if the body starts with an XML declaration ("<?xml"), leading whitespace
+//     aside;
+//   - text/plain otherwise.
+//
+// The detection is only a heuristic: a JSON scalar such as `42` or `"hello"` is reported as JSON,
+// and XML documents or HTML pages without declaration as text.  Set the header explicitly when
+// it matters.
+func NewAutoResponder(status int, body string) Responder {
+	response := NewStringResponse(status, body)
+
+	trimmed := strings.TrimSpace(body)
+	switch {
+	case trimmed != "" && json.Valid([]byte(trimmed)):
+		response.Header.Set("Content-Type", "application/json")
+	case strings.HasPrefix(trimmed, "<?xml"):
+		response.Header.Set("Content-Type", "application/xml")
+	default:
+		response.Header.Set("Content-Type", "text/plain")
+	}
+	return ResponderFromResponse(response)
+}
+
 // NewXmlResponse creates an *http.Response with a body that is an xml encoded representation
 // of the given interface{}.  Also accepts an http status code.
 func NewXmlResponse(status int, body interface{}) (*http.Response, error) {
@@ -93,6 +222,455 @@ func NewXmlResponder(status int, body interface{}) (Responder, error) {
 	return ResponderFromResponse(resp), nil
 }
 
+// NewFSResponder creates a Responder which reads the file name from fsys on each call and returns
+// its content as the body, with the given status code and Content-Type header.  It works nicely
+// with fixtures embedded with //go:embed.  If the file can't be read, the error is returned to
+// the client.
+func NewFSResponder(fsys fs.FS, status int, name, contentType string) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		body, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+
+		response := NewBytesResponse(status, body)
+		if contentType != "" {
+			response.Header.Set("Content-Type", contentType)
+		}
+		return response, nil
+	}
+}
+
+// Part is a single part of a multipart response body built by NewMultipartResponder.
+type Part struct {
+	Name        string
+	ContentType string
+	Body        []byte
+}
+
+// NewMultipartResponse creates an *http.Response with a multipart/form-data body made of the
+// given parts.  The Content-Type header carries the boundary used to separate the parts.  The
+// boundary is random, drawn from the source set by SetRandSource if any; use
+// NewMultipartResponseWithBoundary to choose it.
+func NewMultipartResponse(status int, parts []Part) (*http.Response, error) {
+	return NewMultipartResponseWithBoundary(status, "", parts)
+}
+
+// NewMultipartResponseWithBoundary is the same as NewMultipartResponse but separates the parts
+// with boundary, so that the body is the same byte for byte on each call.  An empty boundary
+// means a random one.
+func NewMultipartResponseWithBoundary(status int, boundary string, parts []Part) (*http.Response, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	if boundary == "" {
+		randMu.Lock()
+		if randSource != nil {
+			boundary = fmt.Sprintf("%016x%016x", randSource.Int63(), randSource.Int63())
+		}
+		randMu.Unlock()
+	}
+	if boundary != "" {
+		if err := w.SetBoundary(boundary); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, part := range parts {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, part.Name))
+		if part.ContentType != "" {
+			header.Set("Content-Type", part.ContentType)
+		}
+
+		pw, err := w.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := pw.Write(part.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	response := NewBytesResponse(status, buf.Bytes())
+	response.Header.Set("Content-Type", w.FormDataContentType())
+	return response, nil
+}
+
+// NewMultipartResponder creates a Responder from the given parts (see NewMultipartResponse) and
+// status code.
+func NewMultipartResponder(status int, parts []Part) (Responder, error) {
+	resp, err := NewMultipartResponse(status, parts)
+	if err != nil {
+		return nil, err
+	}
+	return ResponderFromResponse(resp), nil
+}
+
+// New100ContinueResponder creates a Responder that mimics a server answering an
+// "Expect: 100-continue" request with a 100 Continue informational response before the final
+// response returned by final.
+//
+// A RoundTripper can only hand a single response back to the http.Client, so the informational
+// response never reaches the client as an *http.Response.  Instead, when the request carries the
+// Expect header, the Got100Continue then Got1xxResponse hooks of any httptrace.ClientTrace
+// attached to the request context are fired, in the order the real transport does.  Requests
+// without the Expect header are passed to final untouched.
+func New100ContinueResponder(final Responder) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		if !strings.EqualFold(req.Header.Get("Expect"), "100-continue") {
+			return final(req)
+		}
+
+		// same order as net/http: Got100Continue, then Got1xxResponse
+		if trace := httptrace.ContextClientTrace(req.Context()); trace != nil {
+			if trace.Got100Continue != nil {
+				trace.Got100Continue()
+			}
+			if trace.Got1xxResponse != nil {
+				if err := trace.Got1xxResponse(http.StatusContinue, textproto.MIMEHeader{}); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return final(req)
+	}
+}
+
+// ContextDeadlineSlack is the minimum remaining time a request context must have left for
+// NewContextDeadlineResponder to accept the request.
+var ContextDeadlineSlack = 10 * time.Millisecond
+
+// NewContextDeadlineResponder creates a Responder that models a server refusing work it can't
+// finish in time.  If the request context carries a deadline, the remaining time is computed as
+// the deadline minus the current time (see MockTransport.Now); when it is less than
+// ContextDeadlineSlack the responder returns context.DeadlineExceeded immediately.  Otherwise, or
+// if the context has no deadline, the request is handed to ok.
+func NewContextDeadlineResponder(ok Responder) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		if deadline, has := req.Context().Deadline(); has {
+			if deadline.Sub(now(req)) < ContextDeadlineSlack {
+				return nil, context.DeadlineExceeded
+			}
+		}
+		return ok(req)
+	}
+}
+
+// QueryEchoResponder creates a Responder which copies every query parameter of the incoming
+// request into a "X-Query-<key>" response header, with an empty body and the given status code.
+// Multi-valued parameters are joined with commas, so "?a=1&a=2" gives "X-Query-A: 1,2".
+func QueryEchoResponder(status int) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		response := NewStringResponse(status, "")
+		for key, values := range req.URL.Query() {
+			response.Header.Set("X-Query-"+key, strings.Join(values, ","))
+		}
+		return response, nil
+	}
+}
+
+// HeaderEchoResponder creates a Responder which copies each of the named request headers into a
+// "X-Echo-<name>" response header, with an empty body and the given status code.  All the values
+// of a header are copied, and the headers missing from the request are omitted.  This helps
+// checking that a header, e.g. a correlation ID, is propagated end to end.
+func HeaderEchoResponder(status int, headerNames ...string) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		response := NewStringResponse(status, "")
+		for _, name := range headerNames {
+			for _, v := range req.Header.Values(name) {
+				response.Header.Add("X-Echo-"+name, v)
+			}
+		}
+		return response, nil
+	}
+}
+
+// NewErrorBodyResponder creates a Responder whose response body yields prefix, then fails with
+// readErr on every subsequent Read.  Closing the body always succeeds.  This helps testing the
+// propagation of errors happening in the middle of a body.
+func NewErrorBodyResponder(status int, prefix []byte, readErr error) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		response := NewStringResponse(status, "")
+		response.Body = &errorBody{r: bytes.NewReader(prefix), err: readErr}
+		response.ContentLength = -1
+		return response, nil
+	}
+}
+
+// NewRequireHeadersResponder creates a Responder which checks that each of the required headers
+// is present and non-empty in the request.  If one is missing, a 400 response is returned with a
+// body listing the missing headers, otherwise the request is handed to ok.
+func NewRequireHeadersResponder(required []string, ok Responder) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		var missing []string
+		for _, name := range required {
+			if req.Header.Get(name) == "" {
+				missing = append(missing, http.CanonicalHeaderKey(name))
+			}
+		}
+
+		if len(missing) > 0 {
+			return NewStringResponse(http.StatusBadRequest,
+				"missing required header(s): "+strings.Join(missing, ", ")), nil
+		}
+		return ok(req)
+	}
+}
+
+// NewRoundRobinResponder creates a Responder which cycles through bodies, one per call, as if the
+// requests were served by different backends.  The index of the body used is set in the
+// X-Backend response header.
+//
+// It panics if bodies is empty.
+func NewRoundRobinResponder(bodies []string, status int) Responder {
+	if len(bodies) == 0 {
+		panic("httpmock: NewRoundRobinResponder needs at least one body")
+	}
+
+	var calls uint64
+	return func(req *http.Request) (*http.Response, error) {
+		i := int((atomic.AddUint64(&calls, 1) - 1) % uint64(len(bodies)))
+
+		response := NewStringResponse(status, bodies[i])
+		response.Header.Set("X-Backend", strconv.Itoa(i))
+		return response, nil
+	}
+}
+
+// NewETagResponder creates a Responder serving body with the given ETag header, which must be
+// quoted as in `"v1"`.  When the request carries an If-None-Match header listing this ETag
+// (compared weakly, so W/"v1" matches too) or "*", a 304 Not Modified response with the ETag
+// header and no body is returned instead.
+func NewETagResponder(etag string, body Responder) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		if etagMatches(req.Header.Get("If-None-Match"), etag) {
+			response := NewStringResponse(http.StatusNotModified, "")
+			response.Header.Set("ETag", etag)
+			return response, nil
+		}
+
+		resp, err := body(req)
+		if err != nil || resp == nil {
+			return resp, err
+		}
+
+		resp = cloneResponse(resp)
+		resp.Header.Set("ETag", etag)
+		return resp, nil
+	}
+}
+
+// etagMatches tells whether the If-None-Match header value ifNoneMatch matches etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// NewMethodAssertingResponder creates a Responder which hands the request to ok only if its
+// method is expected.  Otherwise a 405 Method Not Allowed response is returned, with an Allow
+// header set to expected.
+func NewMethodAssertingResponder(expected string, ok Responder) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		if req.Method != expected {
+			response := NewStringResponse(http.StatusMethodNotAllowed,
+				fmt.Sprintf("method %s not allowed, expected %s", req.Method, expected))
+			response.Header.Set("Allow", expected)
+			return response, nil
+		}
+		return ok(req)
+	}
+}
+
+// NewDNSFailureResponder creates a Responder failing like a DNS lookup of host which didn't find
+// it: the error is a *url.Error wrapping a *net.DNSError, so code using errors.As to detect DNS
+// failures behaves as with a real one.
+func NewDNSFailureResponder(host string) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		return nil, &url.Error{
+			Op:  req.Method,
+			URL: req.URL.String(),
+			Err: &net.OpError{
+				Op:  "dial",
+				Net: "tcp",
+				Err: &net.DNSError{
+					Err:        "no such host",
+					Name:       host,
+					IsNotFound: true,
+				},
+			},
+		}
+	}
+}
+
+// NewUnavailableUntilResponder creates a Responder modelling a server coming back up at until:
+// while the current time, as given by MockTransport.Now, is before until, it returns a 503
+// Service Unavailable response with a Retry-After header set to retryAfter, in whole seconds
+// rounded up; afterwards it hands the requests to ready.
+func NewUnavailableUntilResponder(until time.Time, retryAfter time.Duration, ready Responder) Responder {
+	seconds := int64((retryAfter + time.Second - 1) / time.Second)
+	return func(req *http.Request) (*http.Response, error) {
+		if now(req).Before(until) {
+			response := NewStringResponse(http.StatusServiceUnavailable, "service unavailable")
+			response.Header.Set("Retry-After", strconv.FormatInt(seconds, 10))
+			return response, nil
+		}
+		return ready(req)
+	}
+}
+
+// NewScheduleResponder creates a Responder handing each request to the responder of schedule
+// for the current weekday, as given by MockTransport.Now in its location, or to fallback on the
+// days missing from schedule.  If fallback is nil, requests on those days fail with
+// NoResponderFound as if no responder was registered.
+func NewScheduleResponder(schedule map[time.Weekday]Responder, fallback Responder) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		if r, ok := schedule[now(req).Weekday()]; ok {
+			return r(req)
+		}
+		if fallback == nil {
+			return ConnectionFailure(req)
+		}
+		return fallback(req)
+	}
+}
+
+// NewTokenRouterResponder creates a Responder handing each request to the responder of byToken
+// for the bearer token of its "Authorization: Bearer <token>" header, so that one endpoint can
+// serve data specific to each tenant.  The requests without Authorization header, with another
+// scheme or with a token missing from byToken are handed to fallback.  If fallback is nil, they
+// fail with NoResponderFound as if no responder was registered.
+func NewTokenRouterResponder(byToken map[string]Responder, fallback Responder) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		parts := strings.SplitN(req.Header.Get("Authorization"), " ", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "Bearer") {
+			if r, found := byToken[strings.TrimSpace(parts[1])]; found {
+				return r(req)
+			}
+		}
+		if fallback == nil {
+			return ConnectionFailure(req)
+		}
+		return fallback(req)
+	}
+}
+
+// NewPrefixedBodyResponder creates a Responder whose body is prefix followed by body, e.g. a
+// UTF-8 byte order mark ("\xEF\xBB\xBF") or whitespace before a JSON document, to test the
+// robustness of parsers.  The Content-Length covers the whole prefixed body, and the
+// Content-Type header is set to contentType unless it is empty.
+func NewPrefixedBodyResponder(status int, prefix, body []byte, contentType string) Responder {
+	full := make([]byte, 0, len(prefix)+len(body))
+	full = append(append(full, prefix...), body...)
+
+	response := NewBytesResponse(status, full)
+	if contentType != "" {
+		response.Header.Set("Content-Type", contentType)
+	}
+	return ResponderFromResponse(response)
+}
+
+// NewOAuth2TokenResponder creates a Responder mimicking a successful OAuth2 token endpoint
+// (RFC 6749 section 5.1): it returns a 200 response whose JSON body holds accessToken, a
+// "Bearer" token_type and expiresIn, in seconds.
+func NewOAuth2TokenResponder(accessToken string, expiresIn int) Responder {
+	response, _ := NewJsonResponse(http.StatusOK, struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{accessToken, "Bearer", expiresIn})
+	return ResponderFromResponse(response)
+}
+
+// NewPermanentRedirectResponder creates a Responder returning a 301 Moved Permanently response to
+// location, with a "Cache-Control: max-age=31536000" header, i.e. one year, as permanent
+// redirects are usually cached.  The client follows the redirect by sending a new request, so a
+// responder must also be registered for location.
+func NewPermanentRedirectResponder(location string) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		response := NewStringResponse(http.StatusMovedPermanently, "")
+		response.Header.Set("Location", location)
+		response.Header.Set("Cache-Control", "max-age=31536000")
+		return response, nil
+	}
+}
+
+// NewInteractiveResponder creates a Responder whose responses have the given status and, as body,
+// the next line read from r, e.g. os.Stdin, so that responses can be typed while exploring a
+// client by hand.  Each request consumes one line, without its trailing "\n" or "\r\n"; a last
+// line without newline is returned as is.  Once r has no more lines, or fails, requests fail with
+// the read error.  Concurrent requests get distinct lines, in the order they read them.
+func NewInteractiveResponder(status int, r io.Reader) Responder {
+	var mu sync.Mutex
+	lines := bufio.NewReader(r)
+
+	return func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		line, err := lines.ReadString('\n')
+		mu.Unlock()
+
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, fmt.Errorf("httpmock: reading interactive response: %w", err)
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		return NewStringResponse(status, line), nil
+	}
+}
+
+// NewRawResponder creates a Responder replaying raw, a raw HTTP response as sent on the wire
+// (status line, headers, empty line and body), e.g. captured with tcpdump.  raw is parsed with
+// http.ReadResponse, so header names are canonicalized and chunked bodies are decoded, exactly as
+// a client talking to the real server would see them.  Lines may end with "\r\n" or "\n".
+//
+// Each call gets a fresh copy of the response, with its own headers and body.  An error is
+// returned if raw can't be parsed.
+func NewRawResponder(raw string) (Responder, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(raw)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw response: %s", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("invalid raw response body: %s", err)
+	}
+
+	return func(req *http.Request) (*http.Response, error) {
+		c := cloneResponse(resp)
+		c.Request = req
+		if req.Method == "HEAD" {
+			c.Body = http.NoBody
+		} else {
+			c.Body = NewRespBodyFromBytes(body)
+		}
+		return c, nil
+	}, nil
+}
+
+// NewEmptyCloseResponder creates a Responder failing like a server accepting the connection,
+// then closing it without sending a single byte: it returns io.EOF, which http.Client wraps in
+// a *url.Error, giving the same `Get "http://...": EOF` error as with a real server.  Unlike a
+// response whose body is cut short, there is no response at all.
+func NewEmptyCloseResponder() Responder {
+	return func(*http.Request) (*http.Response, error) {
+		return nil, io.EOF
+	}
+}
+
 // NewRespBodyFromString creates an io.ReadCloser from a string that is suitable for use as an
 // http response body.
 func NewRespBodyFromString(body string) io.ReadCloser {
@@ -105,6 +683,23 @@ func NewRespBodyFromBytes(body []byte) io.ReadCloser {
 	return &dummyReadCloser{bytes.NewReader(body)}
 }
 
+// errorBody is the body built by NewErrorBodyResponder.
+type errorBody struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (e *errorBody) Read(p []byte) (int, error) {
+	if e.r.Len() == 0 {
+		return 0, e.err
+	}
+	return e.r.Read(p)
+}
+
+func (e *errorBody) Close() error {
+	return nil
+}
+
 type dummyReadCloser struct {
 	body io.ReadSeeker
 }
@@ -117,6 +712,16 @@ func (d *dummyReadCloser) Read(p []byte) (n int, err error) {
 	return n, err
 }
 
+// size returns the full length of the body, whatever has already been read.
+func (d *dummyReadCloser) size() int64 {
+	if s, ok := d.body.(interface {
+		Size() int64
+	}); ok {
+		return s.Size()
+	}
+	return -1
+}
+
 func (d *dummyReadCloser) Close() error {
 	return nil
 }
@@ -1,11 +1,26 @@
 package httpmock
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
+	"io"
 	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 )
 
 func TestNewStringResponse(t *testing.T) {
@@ -145,3 +160,994 @@ func TestRewindResponse(t *testing.T) {
 		}
 	}
 }
+
+func TestNew100ContinueResponder(t *testing.T) {
+	var hooks []string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			hooks = append(hooks, "Got1xxResponse "+strconv.Itoa(code))
+			return nil
+		},
+		Got100Continue: func() {
+			hooks = append(hooks, "Got100Continue")
+		},
+	}
+
+	responder := New100ContinueResponder(func(req *http.Request) (*http.Response, error) {
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return NewStringResponse(201, string(data)), nil
+	})
+
+	req, err := http.NewRequest("POST", "http://www.example.com/", strings.NewReader("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Expect", "100-continue")
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	response, err := responder(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != 201 {
+		t.Fatalf("expected status 201, got %d", response.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "hello world" {
+		t.Fatalf("expected body to be echoed, got %q", data)
+	}
+
+	// net/http calls Got100Continue first
+	expected := []string{"Got100Continue", "Got1xxResponse 100"}
+	if !reflect.DeepEqual(hooks, expected) {
+		t.Fatalf("expected the hooks %q, got %q", expected, hooks)
+	}
+}
+
+func TestNewContextDeadlineResponder(t *testing.T) {
+	responder := NewContextDeadlineResponder(NewStringResponder(200, "hello world"))
+
+	req, err := http.NewRequest("GET", "http://www.example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no deadline at all
+	if _, err := responder(req); err != nil {
+		t.Fatal(err)
+	}
+
+	// plenty of time left
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := responder(req.WithContext(ctx)); err != nil {
+		t.Fatal(err)
+	}
+
+	// deadline closer than the slack
+	ctx, cancel = context.WithTimeout(context.Background(), ContextDeadlineSlack/2)
+	defer cancel()
+	if _, err := responder(req.WithContext(ctx)); err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestNewMultipartResponse(t *testing.T) {
+	parts := []Part{
+		{Name: "first", ContentType: "application/json", Body: []byte(`{"hello":"world"}`)},
+		{Name: "second", ContentType: "text/plain", Body: []byte("hello world")},
+	}
+
+	response, err := NewMultipartResponse(200, parts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mediaType != "multipart/form-data" || params["boundary"] == "" {
+		t.Fatalf("unexpected Content-Type %q", response.Header.Get("Content-Type"))
+	}
+
+	r := multipart.NewReader(response.Body, params["boundary"])
+	for _, expected := range parts {
+		part, err := r.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if part.FormName() != expected.Name {
+			t.Fatalf("expected part %q, got %q", expected.Name, part.FormName())
+		}
+
+		if part.Header.Get("Content-Type") != expected.ContentType {
+			t.Fatalf("expected Content-Type %q, got %q", expected.ContentType, part.Header.Get("Content-Type"))
+		}
+
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != string(expected.Body) {
+			t.Fatalf("expected body %q, got %q", expected.Body, data)
+		}
+	}
+}
+
+func TestNewMultipartResponseWithBoundary(t *testing.T) {
+	response, err := NewMultipartResponseWithBoundary(200, "fixed-boundary",
+		[]Part{{Name: "field", Body: []byte("value")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ct := response.Header.Get("Content-Type"); ct != "multipart/form-data; boundary=fixed-boundary" {
+		t.Fatalf("unexpected Content-Type %q", ct)
+	}
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "--fixed-boundary\r\n" +
+		"Content-Disposition: form-data; name=\"field\"\r\n\r\n" +
+		"value\r\n" +
+		"--fixed-boundary--\r\n"
+	if string(data) != expected {
+		t.Fatalf("expected body %q, got %q", expected, data)
+	}
+}
+
+func TestQueryEchoResponder(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://www.example.com/?id=1&tag=a&tag=b", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := QueryEchoResponder(204)(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != 204 {
+		t.Fatalf("expected status 204, got %d", response.StatusCode)
+	}
+
+	if v := response.Header.Get("X-Query-Id"); v != "1" {
+		t.Fatalf("expected X-Query-Id to be 1, got %q", v)
+	}
+
+	if v := response.Header.Get("X-Query-Tag"); v != "a,b" {
+		t.Fatalf("expected X-Query-Tag to be a,b, got %q", v)
+	}
+}
+
+func TestHeaderEchoResponder(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://www.example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Request-Id", "42")
+	req.Header.Add("Traceparent", "00-a-b-01")
+	req.Header.Add("Traceparent", "00-c-d-01")
+
+	response, err := HeaderEchoResponder(200, "X-Request-Id", "traceparent", "X-Missing")(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := response.Header.Get("X-Echo-X-Request-Id"); v != "42" {
+		t.Fatalf("expected X-Echo-X-Request-Id to be 42, got %q", v)
+	}
+
+	expected := []string{"00-a-b-01", "00-c-d-01"}
+	if v := response.Header.Values("X-Echo-Traceparent"); !reflect.DeepEqual(v, expected) {
+		t.Fatalf("expected X-Echo-Traceparent to be %q, got %q", expected, v)
+	}
+
+	if _, ok := response.Header["X-Echo-X-Missing"]; ok {
+		t.Fatal("expected no X-Echo-X-Missing header")
+	}
+}
+
+func TestZeroStatusResponse(t *testing.T) {
+	jsonResponse, err := NewJsonResponse(0, "hello world")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	xmlResponse, err := NewXmlResponse(0, "hello world")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responses := []*http.Response{
+		NewStringResponse(0, "hello world"),
+		NewBytesResponse(0, []byte("hello world")),
+		jsonResponse,
+		xmlResponse,
+	}
+
+	for _, response := range responses {
+		if response.StatusCode != 200 || response.Status != "200" {
+			t.Fatalf("expected status 200, got %d (%q)", response.StatusCode, response.Status)
+		}
+	}
+
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl, NewStringResponder(0, "hello world"))
+
+	resp, err := http.Get(testUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestNewFSResponder(t *testing.T) {
+	fsys := fstest.MapFS{
+		"testdata/article.json": &fstest.MapFile{Data: []byte(`{"id":1}`)},
+	}
+
+	req, err := http.NewRequest("GET", "http://www.example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := NewFSResponder(fsys, 200, "testdata/article.json", "application/json")(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Header.Get("Content-Type") != "application/json" {
+		t.FailNow()
+	}
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != `{"id":1}` {
+		t.Fatalf("unexpected body %q", data)
+	}
+
+	if _, err := NewFSResponder(fsys, 200, "missing.json", "")(req); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
+
+func TestResponderHeadRequest(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	responder := NewStringResponder(200, "hello world")
+	RegisterResponder("GET", testUrl, responder)
+	RegisterResponder("HEAD", testUrl, responder)
+
+	resp, err := http.Get(testUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "hello world" || resp.ContentLength != 11 {
+		t.Fatalf("unexpected GET response: %q (Content-Length %d)", data, resp.ContentLength)
+	}
+
+	resp, err = http.Head(testUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != 0 {
+		t.Fatalf("expected no body for HEAD, got %q", data)
+	}
+
+	if resp.ContentLength != 11 || resp.Header.Get("Content-Length") != "11" {
+		t.Fatalf("expected a Content-Length of 11, got %d (%q)",
+			resp.ContentLength, resp.Header.Get("Content-Length"))
+	}
+
+	// dynamic responders building a new response per request are handled too
+	RegisterResponder("HEAD", testUrl+"dynamic", func(req *http.Request) (*http.Response, error) {
+		return NewStringResponse(200, "dynamic "+req.Method), nil
+	})
+
+	resp, err = http.Head(testUrl + "dynamic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(data) != 0 {
+		t.Fatalf("expected no body for HEAD, got %q", data)
+	}
+
+	if resp.ContentLength != 12 {
+		t.Fatalf("expected a Content-Length of 12, got %d", resp.ContentLength)
+	}
+}
+
+func TestNewErrorBodyResponder(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://www.example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := NewErrorBodyResponder(200, []byte("hello"), io.ErrClosedPipe)(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != io.ErrClosedPipe {
+		t.Fatalf("expected io.ErrClosedPipe, got %v", err)
+	}
+
+	if string(data) != "hello" {
+		t.Fatalf("expected the prefix to be read first, got %q", data)
+	}
+
+	if err := response.Body.Close(); err != nil {
+		t.Fatalf("expected Close to succeed, got %v", err)
+	}
+}
+
+func TestNewRequireHeadersResponder(t *testing.T) {
+	responder := NewRequireHeadersResponder([]string{"Authorization", "x-api-key"},
+		NewStringResponder(200, "hello world"))
+
+	req, err := http.NewRequest("GET", "http://www.example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer token")
+
+	response, err := responder(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != 400 {
+		t.Fatalf("expected status 400, got %d", response.StatusCode)
+	}
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "missing required header(s): X-Api-Key" {
+		t.Fatalf("unexpected body %q", data)
+	}
+
+	req.Header.Set("X-Api-Key", "secret")
+
+	response, err = responder(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", response.StatusCode)
+	}
+}
+
+func TestNewRoundRobinResponder(t *testing.T) {
+	responder := NewRoundRobinResponder([]string{"a", "b", "c"}, 200)
+
+	req, err := http.NewRequest("GET", "http://www.example.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []string{"a", "b", "c", "a", "b"} {
+		response, err := responder(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != expected {
+			t.Fatalf("call #%d: expected body %q, got %q", i+1, expected, data)
+		}
+
+		if backend := response.Header.Get("X-Backend"); backend != strconv.Itoa(i%3) {
+			t.Fatalf("call #%d: expected X-Backend %d, got %q", i+1, i%3, backend)
+		}
+	}
+}
+
+func TestNewRoundRobinResponderEmpty(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for empty bodies")
+		}
+	}()
+	NewRoundRobinResponder(nil, 200)
+}
+
+func TestNewETagResponder(t *testing.T) {
+	responder := NewETagResponder(`"v1"`, NewStringResponder(200, "hello world"))
+
+	tests := []struct {
+		ifNoneMatch string
+		status      int
+		body        string
+	}{
+		{"", 200, "hello world"},
+		{`"v0"`, 200, "hello world"},
+		{`"v1"`, 304, ""},
+		{`"v0", W/"v1"`, 304, ""},
+		{"*", 304, ""},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest("GET", "http://www.example.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if test.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", test.ifNoneMatch)
+		}
+
+		response, err := responder(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.StatusCode != test.status {
+			t.Fatalf("If-None-Match %s: expected status %d, got %d", test.ifNoneMatch, test.status, response.StatusCode)
+		}
+
+		if response.Header.Get("ETag") != `"v1"` {
+			t.Fatalf("If-None-Match %s: expected the ETag header, got %q", test.ifNoneMatch, response.Header.Get("ETag"))
+		}
+
+		data, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != test.body {
+			t.Fatalf("If-None-Match %s: expected body %q, got %q", test.ifNoneMatch, test.body, data)
+		}
+	}
+}
+
+func TestNewDNSFailureResponder(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl, NewDNSFailureResponder("www.example.com"))
+
+	_, err := http.Get(testUrl)
+	if err == nil {
+		t.Fatal("expected a DNS error")
+	}
+
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		t.Fatalf("expected the error to unwrap to a *net.DNSError, got %v", err)
+	}
+
+	if !dnsErr.IsNotFound || dnsErr.Name != "www.example.com" {
+		t.Fatalf("unexpected DNS error %+v", dnsErr)
+	}
+}
+
+func TestNewMethodAssertingResponder(t *testing.T) {
+	responder := NewMethodAssertingResponder("POST", NewStringResponder(201, "created"))
+
+	for method, status := range map[string]int{"POST": 201, "PUT": 405} {
+		req, err := http.NewRequest(method, "http://www.example.com/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		response, err := responder(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.StatusCode != status {
+			t.Fatalf("%s: expected status %d, got %d", method, status, response.StatusCode)
+		}
+
+		if status == 405 && response.Header.Get("Allow") != "POST" {
+			t.Fatalf("expected Allow header to be POST, got %q", response.Header.Get("Allow"))
+		}
+	}
+}
+
+func TestNewOAuth2TokenResponder(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("POST", "https://auth.example.com/token", NewOAuth2TokenResponder("s3cr3t", 3600))
+
+	response, err := http.PostForm("https://auth.example.com/token", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", response.StatusCode)
+	}
+
+	if response.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected Content-Type %q", response.Header.Get("Content-Type"))
+	}
+
+	// same fields and types golang.org/x/oauth2 decodes token responses into
+	var token struct {
+		AccessToken string      `json:"access_token"`
+		TokenType   string      `json:"token_type"`
+		ExpiresIn   json.Number `json:"expires_in"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil {
+		t.Fatal(err)
+	}
+
+	if token.AccessToken != "s3cr3t" || token.TokenType != "Bearer" || token.ExpiresIn != "3600" {
+		t.Fatalf("unexpected token %+v", token)
+	}
+}
+
+func TestNewRawResponder(t *testing.T) {
+	raw := "HTTP/1.1 201 Created\r\n" +
+		"content-type: application/json\r\n" +
+		"X-REQUEST-ID: abc123\r\n" +
+		"Set-Cookie: a=1\r\n" +
+		"Set-Cookie: b=2\r\n" +
+		"Content-Length: 8\r\n" +
+		"\r\n" +
+		`{"id":1}`
+
+	responder, err := NewRawResponder(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		response, err := responder(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if response.StatusCode != 201 || response.Status != "201 Created" {
+			t.Fatalf("unexpected status %q", response.Status)
+		}
+
+		if response.Header.Get("Content-Type") != "application/json" ||
+			response.Header.Get("X-Request-Id") != "abc123" ||
+			len(response.Header["Set-Cookie"]) != 2 {
+			t.Fatalf("unexpected headers %v", response.Header)
+		}
+
+		data, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != `{"id":1}` {
+			t.Fatalf("call #%d: unexpected body %q", i+1, data)
+		}
+
+		// altering a response doesn't affect the next ones
+		response.Header.Del("X-Request-Id")
+	}
+
+	if _, err := NewRawResponder("not an HTTP response"); err == nil {
+		t.Fatal("expected an error for a malformed response")
+	}
+}
+
+func TestNewUnavailableUntilResponder(t *testing.T) {
+	start := time.Date(2016, 12, 10, 0, 0, 0, 0, time.UTC)
+	clock := &fakeClock{current: start}
+
+	mock := NewMockTransport()
+	clock.install(mock)
+	mock.RegisterResponder("GET", testUrl, NewUnavailableUntilResponder(start.Add(time.Minute),
+		1500*time.Millisecond, NewStringResponder(200, "ready")))
+
+	client := &http.Client{Transport: mock}
+
+	resp, err := client.Get(testUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != 503 || resp.Header.Get("Retry-After") != "2" {
+		t.Fatalf("expected a 503 with Retry-After 2, got %d %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+
+	// past the deadline
+	clock.Sleep(context.Background(), time.Minute)
+
+	resp, err = client.Get(testUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != 200 || string(data) != "ready" {
+		t.Fatalf("expected the ready response, got %d %q", resp.StatusCode, data)
+	}
+}
+
+func TestNewPrefixedBodyResponder(t *testing.T) {
+	bom := []byte("\xEF\xBB\xBF")
+	body := []byte(`{"hello":"world"}`)
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := NewPrefixedBodyResponder(200, bom, body, "application/json")(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.ContentLength != int64(len(bom)+len(body)) {
+		t.Fatalf("expected ContentLength %d, got %d", len(bom)+len(body), response.ContentLength)
+	}
+
+	if response.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected Content-Type %q", response.Header.Get("Content-Type"))
+	}
+
+	data, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != string(bom)+string(body) {
+		t.Fatalf("unexpected body %q", data)
+	}
+
+	// a strict JSON decoder chokes on the BOM, which is the point
+	var v map[string]string
+	if err := json.Unmarshal(data, &v); err == nil {
+		t.Fatal("expected the BOM to break json.Unmarshal")
+	}
+	if err := json.Unmarshal(data[len(bom):], &v); err != nil || v["hello"] != "world" {
+		t.Fatalf("expected the stripped body to decode, got %v, %v", v, err)
+	}
+}
+
+func TestNewScheduleResponder(t *testing.T) {
+	// a Saturday
+	clock := &fakeClock{current: time.Date(2016, 12, 10, 12, 0, 0, 0, time.UTC)}
+
+	mock := NewMockTransport()
+	clock.install(mock)
+	mock.RegisterResponder("GET", testUrl, NewScheduleResponder(map[time.Weekday]Responder{
+		time.Saturday: NewStringResponder(200, "weekend batch"),
+		time.Monday:   NewStringResponder(200, "weekly report"),
+	}, NewStringResponder(204, "")))
+
+	client := &http.Client{Transport: mock}
+
+	for _, expected := range []string{"weekend batch", "", "weekly report", ""} {
+		resp, err := client.Get(testUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != expected {
+			t.Fatalf("%s: expected body %q, got %q", clock.Now().Weekday(), expected, data)
+		}
+
+		clock.Sleep(context.Background(), 24*time.Hour)
+	}
+}
+
+func TestNewEmptyCloseResponder(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl, NewEmptyCloseResponder())
+
+	resp, err := http.Get(testUrl)
+	if err == nil {
+		t.Fatalf("expected an error, got a response %v", resp)
+	}
+
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Fatalf("expected a *url.Error, got %T", err)
+	}
+
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("expected the error to wrap io.EOF, got %v", err)
+	}
+}
+
+func TestNewAutoResponder(t *testing.T) {
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]string{
+		`{"hello": "world"}`: "application/json",
+		"  [1, 2, 3]\n":      "application/json",
+		`<?xml version="1.0"?><hello>world</hello>`: "application/xml",
+		"\n<?xml version=\"1.0\"?><a/>":             "application/xml",
+		"hello world":                               "text/plain",
+		`{"truncated":`:                             "text/plain",
+		"":                                          "text/plain",
+	}
+
+	for body, expected := range tests {
+		response, err := NewAutoResponder(200, body)(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ct := response.Header.Get("Content-Type"); ct != expected {
+			t.Fatalf("%q: expected Content-Type %q, got %q", body, expected, ct)
+		}
+
+		data, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != body {
+			t.Fatalf("expected body %q, got %q", body, data)
+		}
+	}
+}
+
+func TestResponseResponder(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	tests := []struct {
+		path     string
+		response Response
+	}{
+		{"articles", Response{Status: 200, Body: "[]", Headers: http.Header{"content-type": {"application/json"}}}},
+		{"users", Response{Status: 404, Body: "not found"}},
+		{"ping", Response{Body: "pong"}},
+	}
+
+	for _, test := range tests {
+		RegisterResponder("GET", testUrl+test.path, test.response.Responder())
+	}
+
+	for _, test := range tests {
+		resp, err := http.Get(testUrl + test.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		status := test.response.Status
+		if status == 0 {
+			status = 200
+		}
+
+		if resp.StatusCode != status || string(data) != test.response.Body {
+			t.Fatalf("%s: expected %d %q, got %d %q", test.path, status, test.response.Body, resp.StatusCode, data)
+		}
+
+		for k := range test.response.Headers {
+			if resp.Header.Get(k) != test.response.Headers[k][0] {
+				t.Fatalf("%s: expected header %s, got %v", test.path, k, resp.Header)
+			}
+		}
+	}
+}
+
+func TestResponderFromHandler(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(201)
+		json.NewEncoder(w).Encode(map[string]string{"path": r.URL.Path})
+	})
+
+	mock := NewMockTransport()
+	mock.RegisterResponder("GET", testUrl+"articles", ResponderFromHandler(handler))
+
+	client := &http.Client{Transport: mock}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(testUrl + "articles")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != 201 {
+			t.Fatalf("expected status 201, got %d", resp.StatusCode)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("expected application/json, got %q", ct)
+		}
+
+		var body map[string]string
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		if body["path"] != "/articles" {
+			t.Fatalf("expected path /articles, got %q", body["path"])
+		}
+	}
+}
+
+func TestNewPermanentRedirectResponder(t *testing.T) {
+	mock := NewMockTransport()
+	mock.RegisterResponder("GET", testUrl+"old", NewPermanentRedirectResponder(testUrl+"new"))
+	mock.RegisterResponder("GET", testUrl+"new", NewStringResponder(200, "new place"))
+
+	// not following the redirect
+	req, err := http.NewRequest("GET", testUrl+"old", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := mock.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 301 {
+		t.Fatalf("expected status 301, got %d", resp.StatusCode)
+	}
+	if loc := resp.Header.Get("Location"); loc != testUrl+"new" {
+		t.Fatalf("expected Location %q, got %q", testUrl+"new", loc)
+	}
+	if cc := resp.Header.Get("Cache-Control"); !strings.HasPrefix(cc, "max-age=") {
+		t.Fatalf("expected a max-age Cache-Control, got %q", cc)
+	}
+
+	// following it
+	client := &http.Client{Transport: mock}
+	resp, err = client.Get(testUrl + "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || string(data) != "new place" {
+		t.Fatalf("expected 200 new place, got %d %q", resp.StatusCode, data)
+	}
+}
+
+func TestNewInteractiveResponder(t *testing.T) {
+	input := bytes.NewBufferString("{\"id\": 1}\r\nsecond line")
+	responder := NewInteractiveResponder(200, input)
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{`{"id": 1}`, "second line"} {
+		resp, err := responder(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != 200 || string(data) != expected {
+			t.Fatalf("expected 200 %q, got %d %q", expected, resp.StatusCode, data)
+		}
+	}
+
+	if _, err := responder(req); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF once the input is exhausted, got %v", err)
+	}
+}
+
+func TestNewTokenRouterResponder(t *testing.T) {
+	responder := NewTokenRouterResponder(map[string]Responder{
+		"tenant-a": NewStringResponder(200, "data of a"),
+		"tenant-b": NewStringResponder(200, "data of b"),
+	}, NewStringResponder(401, "unauthorized"))
+
+	tests := map[string]string{
+		"Bearer tenant-a":    "data of a",
+		"bearer tenant-b":    "data of b",
+		"Bearer tenant-c":    "unauthorized",
+		"Basic dGVuYW50LWE=": "unauthorized",
+		"tenant-a":           "unauthorized",
+		"":                   "unauthorized",
+	}
+
+	for authorization, expected := range tests {
+		req, err := http.NewRequest("GET", testUrl, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		resp, err := responder(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Fatalf("%q: expected body %q, got %q", authorization, expected, data)
+		}
+	}
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTokenRouterResponder(nil, nil)(req); err != NoResponderFound {
+		t.Fatalf("expected NoResponderFound without fallback, got %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+package httpmock
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// packageDir is the directory of the sources of this package.
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// callerSite returns the "file:line" of the code which called into this package, skipping the
+// frames of the package itself (but not of its tests), or "" if it can't be found.  A single
+// frame isn't enough as registrations can go through the package-level wrappers or helpers like
+// RegisterResponderDir, so up to 8 frames are walked; it is only called when
+// MockTransport.RecordRegistrationSites is set.
+func callerSite() string {
+	var pcs [8]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs[:])])
+	for {
+		frame, more := frames.Next()
+		if filepath.Dir(frame.File) != packageDir || strings.HasSuffix(frame.File, "_test.go") {
+			return fmt.Sprintf("%s:%d", frame.File, frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
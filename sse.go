@@ -0,0 +1,86 @@
+package httpmock
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NewSSEResponder creates a Responder streaming events as a text/event-stream (server-sent
+// events) body.  Each event is sent as a "data:" field, one per line of the event, followed by
+// an empty line.  The first event is available right away, then each following one after
+// interval.  The waits use the clock of the MockTransport (see MockTransport.Sleep).
+//
+// Reading the body fails with a context error once the stream context is done, i.e. when the
+// request context is done or the MockTransport is reset or deactivated (see StreamContext), and
+// closing the body interrupts a pending wait.  The events are produced as the body is read, no
+// goroutine is left running once the body is closed or the stream context is done.
+func NewSSEResponder(events []string, interval time.Duration) Responder {
+	return func(req *http.Request) (*http.Response, error) {
+		ctx, cancel := StreamContext(req)
+
+		response := NewStringResponse(http.StatusOK, "")
+		response.ContentLength = -1
+		response.Header.Set("Content-Type", "text/event-stream")
+		response.Header.Set("Cache-Control", "no-cache")
+		response.Body = &sseBody{
+			req:      req.WithContext(ctx),
+			cancel:   cancel,
+			events:   events,
+			interval: interval,
+		}
+		return response, nil
+	}
+}
+
+// sseBody is the body of the responses of NewSSEResponder.
+type sseBody struct {
+	req      *http.Request
+	cancel   context.CancelFunc
+	events   []string
+	interval time.Duration
+	sent     int
+	pending  string
+}
+
+func (s *sseBody) Read(p []byte) (int, error) {
+	if err := s.req.Context().Err(); err != nil {
+		return 0, err
+	}
+
+	if s.pending == "" {
+		if s.sent == len(s.events) {
+			return 0, io.EOF
+		}
+		if s.sent > 0 {
+			if err := sleepContext(s.req, s.interval); err != nil {
+				return 0, err
+			}
+		}
+		s.pending = formatSSEEvent(s.events[s.sent])
+		s.sent++
+	}
+
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *sseBody) Close() error {
+	s.cancel()
+	return nil
+}
+
+// formatSSEEvent formats event as the data field of a server-sent event.
+func formatSSEEvent(event string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(event, "\n") {
+		b.WriteString("data: ")
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
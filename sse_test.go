@@ -0,0 +1,127 @@
+package httpmock
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewSSEResponder(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2016, 12, 10, 0, 0, 0, 0, time.UTC)}
+
+	mock := NewMockTransport()
+	clock.install(mock)
+	mock.RegisterResponder("GET", testUrl, NewSSEResponder([]string{"first", "second"}, time.Second))
+
+	resp, err := (&http.Client{Transport: mock}).Get(testUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected Content-Type %q", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var lines []string
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"data: first", "", "data: second", ""}
+	if len(lines) != len(expected) {
+		t.Fatalf("expected lines %q, got %q", expected, lines)
+	}
+	for i := range expected {
+		if lines[i] != expected[i] {
+			t.Fatalf("expected lines %q, got %q", expected, lines)
+		}
+	}
+
+	if elapsed := clock.Now().Sub(time.Date(2016, 12, 10, 0, 0, 0, 0, time.UTC)); elapsed != time.Second {
+		t.Fatalf("expected 1 interval to elapse, got %s", elapsed)
+	}
+}
+
+func TestNewSSEResponderClose(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl, NewSSEResponder([]string{"first", "never"}, time.Hour))
+
+	resp, err := http.Get(testUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 64)
+	if _, err := resp.Body.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := resp.Body.Read(buf)
+		done <- err
+	}()
+
+	resp.Body.Close()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("closing the body didn't interrupt the stream")
+	}
+}
+
+func TestNewSSEResponderStoppedByResetAndDeactivate(t *testing.T) {
+	for name, stop := range map[string]func(){
+		"Reset":      func() { Reset() },
+		"Deactivate": func() { Deactivate() },
+	} {
+		t.Run(name, func(t *testing.T) {
+			Activate()
+			defer DeactivateAndReset()
+
+			RegisterResponder("GET", testUrl, NewSSEResponder([]string{"first", "never"}, time.Hour))
+
+			resp, err := http.Get(testUrl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			buf := make([]byte, 64)
+			if _, err := resp.Body.Read(buf); err != nil {
+				t.Fatal(err)
+			}
+
+			// the streaming goroutine, blocked until the next event
+			done := make(chan error)
+			go func() {
+				_, err := resp.Body.Read(buf)
+				done <- err
+			}()
+
+			stop()
+
+			select {
+			case err := <-done:
+				if err != context.Canceled {
+					t.Fatalf("expected context.Canceled, got %v", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("%s didn't stop the stream", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package httpmock
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// transcriptEntry is a single line of a transcript, see EnableTranscript.
+type transcriptEntry struct {
+	Method       string `json:"method"`
+	URL          string `json:"url"`
+	RequestBody  string `json:"request_body"`
+	Status       int    `json:"status"`
+	ResponseBody string `json:"response_body"`
+	Error        string `json:"error,omitempty"`
+}
+
+// EnableTranscript makes the MockTransport append one JSON line per interaction to w, so the
+// transcript can later be compared against a golden file.  Each line is an object of the form:
+//
+//	{"method":"POST","url":"http://example.com/","request_body":"...","status":200,"response_body":"..."}
+//
+// If the responder returned an error, status is 0 and an additional "error" field holds the
+// error message.  Both bodies are buffered and restored, so recording doesn't prevent the
+// responder or the client from reading them.  request_body requires BufferRequestBodies, it is
+// empty otherwise.  Passing a nil writer disables the transcript.
+func (m *MockTransport) EnableTranscript(w io.Writer) {
+	m.mu.Lock()
+	m.transcript = w
+	m.mu.Unlock()
+}
+
+// EnableTranscript enables the transcript of DefaultTransport.
+func EnableTranscript(w io.Writer) {
+	DefaultTransport.EnableTranscript(w)
+}
+
+// transcribe calls responder and writes the interaction to w.
+func (m *MockTransport) transcribe(w io.Writer, req *http.Request, responder Responder) (*http.Response, error) {
+	entry := transcriptEntry{
+		Method: req.Method,
+		URL:    req.URL.String(),
+	}
+
+	if m.BufferRequestBodies {
+		body, err := ReadBody(req)
+		if err != nil {
+			return nil, err
+		}
+		entry.RequestBody = string(body)
+	}
+
+	resp, err := responder(req)
+	if fallsThrough(err) {
+		// not an interaction, the request falls through to another responder
+		return resp, err
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else if resp != nil {
+		entry.Status = resp.StatusCode
+		if resp.Body != nil {
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				return nil, err
+			}
+			resp.Body.Close()
+			resp.Body = NewRespBodyFromBytes(body)
+			entry.ResponseBody = string(body)
+		}
+	}
+
+	line, merr := json.Marshal(entry)
+	if merr != nil {
+		return nil, merr
+	}
+
+	m.mu.Lock()
+	_, werr := w.Write(append(line, '\n'))
+	m.mu.Unlock()
+	if werr != nil {
+		return nil, werr
+	}
+
+	return resp, err
+}
@@ -0,0 +1,46 @@
+package httpmock
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMockTransportTranscript(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+	DefaultTransport.BufferRequestBodies = true
+	defer func() { DefaultTransport.BufferRequestBodies = false }()
+
+	var transcript bytes.Buffer
+	EnableTranscript(&transcript)
+
+	RegisterResponder("POST", testUrl, func(req *http.Request) (*http.Response, error) {
+		data, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		return NewStringResponse(201, "got "+string(data)), nil
+	})
+
+	resp, err := http.Post(testUrl, "text/plain", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "got hello" {
+		t.Fatalf("expected the client to still read the body, got %q", data)
+	}
+
+	expected := `{"method":"POST","url":"` + testUrl + `","request_body":"hello","status":201,"response_body":"got hello"}` + "\n"
+	if transcript.String() != expected {
+		t.Fatalf("unexpected transcript:\n%s\nexpected:\n%s", transcript.String(), expected)
+	}
+}
@@ -1,9 +1,20 @@
 package httpmock
 
 import (
+	"context"
 	"errors"
+	"expvar"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Responders are callbacks that receive and http request and return a mocked response.
@@ -12,6 +23,13 @@ type Responder func(*http.Request) (*http.Response, error)
 // NoResponderFound is returned when no responders are found for a given HTTP method and URL.
 var NoResponderFound = errors.New("no responder found")
 
+// NilRequestURL is returned when a request without URL is sent through a MockTransport.
+var NilRequestURL = errors.New("request URL is nil")
+
+// MaxRequestsExceeded is returned for the requests exceeding the limit set with SetMaxRequests,
+// when no other responder is given.
+var MaxRequestsExceeded = errors.New("maximum number of requests exceeded")
+
 // ConnectionFailure is a responder that returns a connection failure.  This is the default
 // responder, and is called when no other matching responder is found.
 func ConnectionFailure(*http.Request) (*http.Response, error) {
@@ -20,49 +38,539 @@ func ConnectionFailure(*http.Request) (*http.Response, error) {
 
 // NewMockTransport creates a new *MockTransport with no responders.
 func NewMockTransport() *MockTransport {
-	return &MockTransport{make(map[string]Responder), nil}
+	return &MockTransport{
+		responders:    make(map[string]Responder),
+		callCountInfo: make(map[string]int),
+	}
 }
 
 // MockTransport implements http.RoundTripper, which fulfills single http requests issued by
 // an http.Client.  This implementation doesn't actually make the call, instead deferring to
 // the registered list of responders.
 type MockTransport struct {
-	responders  map[string]Responder
-	noResponder Responder
+	// inFlight and maxInFlight count the concurrent requests, see MaxConcurrentRequests.  They
+	// come first so that they are 64-bit aligned for the atomic operations on 32-bit platforms.
+	inFlight    int64
+	maxInFlight int64
+
+	// requestBytes sums the sizes of the request bodies, see TotalRequestBytes.  It is 64-bit
+	// aligned as well.
+	requestBytes int64
+
+	// Now returns the current time as seen by the responders called through this transport
+	// (Delay, DelayJitter, NewContextDeadlineResponder...).  time.Now is used if nil.
+	Now func() time.Time
+
+	// Sleep waits for d, or until ctx is done in which case it returns ctx.Err().  It is used by
+	// the delaying responders called through this transport, so a fake clock can make them
+	// return instantly.  A real timer is used if nil.
+	Sleep func(ctx context.Context, d time.Duration) error
+
+	// SortQueryParams makes querystring parameters match whatever their order, so that a
+	// responder registered for "/?a=1&b=2" also handles requests for "/?b=2&a=1".  The values of
+	// a repeated parameter must still come in the same order.  If several responders match, e.g.
+	// "/?a=1&b=2" and "/?b=2&a=1", the first one in lexical order wins.  Set it before sending
+	// requests.
+	SortQueryParams bool
+
+	// IgnorePort makes the port of the URLs not matter, so that a responder registered for
+	// "http://127.0.0.1/users" handles the requests to "http://127.0.0.1:54321/users", e.g. sent
+	// to an httptest server bound to a random port, and conversely.  A responder registered with
+	// the exact port of the request still wins, then, among the other ports, the first registered
+	// URL in lexical order.  Calls are counted under the registered URL.
+	IgnorePort bool
+
+	// BufferRequestBodies makes the transport read and buffer the request bodies, restoring them
+	// before calling the responders, so that RequestBody, AssertBodyMatched, UnmatchedRequests
+	// and the transcript can report them.  It is off by default, leaving the bodies untouched for
+	// the responders, e.g. to test streaming uploads without holding them in memory; the features
+	// above then see no body.  Set it before sending requests.
+	BufferRequestBodies bool
+
+	// RecordRegistrationSites makes RegisterResponder record the file:line of the code registering
+	// each responder, so that AssertAllRespondersCalled can point to the setup of the dead mocks.
+	// It is off by default to keep registrations cheap.  Set it before registering responders.
+	RecordRegistrationSites bool
+
+	mu             sync.RWMutex
+	responders     map[string]Responder
+	noResponder    Responder
+	noResponders   map[string]Responder
+	callCountInfo  map[string]int
+	totalCallCount int
+	callOrder      []string
+	timestamps     []time.Time
+	requestCount   int
+	nthHooks       map[int]Responder
+	maxRequests    int
+	onExceed       Responder
+	transcript     io.Writer
+	matchers       []matcherResponder
+	lastRequests   map[string]recordedRequest
+	unmatched      []recordedRequest
+	disabled       map[string]bool
+	prefixes       map[string]Responder
+	queryRegexps   []queryRegexpResponder
+	pathResponders []pathResponder
+	passThrough    map[string]bool
+	sites          map[string]string
+	uaResponders   map[string][]uaResponder
+	expvarTotal    *expvar.Int
+	expvarByKey    *expvar.Map
+	defaultHeaders map[string]http.Header
+
+	// usedUp holds the ids of the responders used up (see Responder.Times), so that Match skips
+	// them as well.  An id is forgotten when another responder is registered in place of the used
+	// up one.
+	usedUp map[string]bool
+
+	// store is the key/value store of the responders, see Store.
+	store sync.Map
+
+	// streams is cancelled by Reset and Deactivate to stop the streaming responders, see
+	// StreamContext.
+	streams     context.Context
+	stopStreams context.CancelFunc
+}
+
+// transportKey is the request context key holding the *MockTransport the request went through.
+type transportKey struct{}
+
+// transportFromRequest returns the *MockTransport req went through, or nil.
+func transportFromRequest(req *http.Request) *MockTransport {
+	m, _ := req.Context().Value(transportKey{}).(*MockTransport)
+	return m
+}
+
+// queryKey is the request context key holding the parsed querystring of the request, see
+// MatchedQuery.
+type queryKey struct{}
+
+// MatchedQuery returns the querystring parameters of req, as parsed once by the MockTransport req
+// went through, so that a dynamic responder, e.g. one registered with
+// RegisterQueryRegexpResponder, doesn't have to parse req.URL.Query() again.  The returned values
+// are shared by all the responders the request is given to and must not be modified.  Outside of
+// a MockTransport, the querystring of req is parsed on each call.
+func MatchedQuery(req *http.Request) url.Values {
+	if query, ok := req.Context().Value(queryKey{}).(url.Values); ok {
+		return query
+	}
+	return req.URL.Query()
+}
+
+// usedUpKey is the request context key holding the flag set by the Responders created by Times on
+// their last allowed call, so that the MockTransport skips them in Match from then on.
+type usedUpKey struct{}
+
+// uaResponder is a responder registered with RegisterResponderWithUserAgent.
+type uaResponder struct {
+	substring string
+	responder Responder
+}
+
+// queryRegexpResponder is a responder registered with RegisterQueryRegexpResponder.
+type queryRegexpResponder struct {
+	// key is the "METHOD URL" key of the URL without querystring.
+	key       string
+	re        *regexp.Regexp
+	responder Responder
+}
+
+// matcherResponder is a responder registered with RegisterMatcherResponderWithPriority.
+type matcherResponder struct {
+	match     func(*http.Request) bool
+	responder Responder
+	priority  int
+}
+
+// countKey returns the "METHOD URL =~ REGEXP" key the calls to qr are counted under.
+func (qr queryRegexpResponder) countKey() string {
+	return qr.key + queryRegexpSeparator + qr.re.String()
 }
 
 // RoundTrip receives HTTP requests and routes them to the appropriate responder.  It is required to
 // implement the http.RoundTripper interface.  You will not interact with this directly, instead
 // the *http.Client you are using will call it for you.
+//
+// The request is matched on its method and req.URL.String().  For an opaque URL (one with
+// req.URL.Opaque set) this gives scheme:opaque followed by the querystring, so a request with
+// Opaque "//example.com/a%2Fb" matches a responder registered for "http://example.com/a%2Fb".
+// A request without URL fails with NilRequestURL.  The responses to HEAD requests are stripped
+// of their body, keeping the Content-Length it has when known, whatever the responder.
+//
+// The responders are consulted in this order, the first match winning:
+//  1. the limit set with SetMaxRequests, then the nth request hooks (see SetNthRequestHook);
+//  2. the responder registered for the exact method and URL (see RegisterResponder), possibly
+//     ignoring the order of the querystring parameters (see SortQueryParams);
+//  3. the querystring regexp responders (see RegisterQueryRegexpResponder), then the responder
+//     registered for the URL without its querystring;
+//  4. the path pattern responders (see RegisterPathResponder);
+//  5. the prefix responders (see RegisterPrefixResponder);
+//  6. the matcher responders (see RegisterMatcherResponder);
+//  7. the responder for any URL of the method (see RegisterResponderAnyURL);
+//  8. the 'no responder' of the method (see RegisterNoResponderForMethod);
+//  9. the real network for the pass-through methods (see RegisterPassThroughMethod);
+//  10. the 'no responder' (see RegisterNoResponder), ConnectionFailure by default.
 func (m *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
-	url := req.URL.String()
+	if req.URL == nil {
+		return nil, NilRequestURL
+	}
+
+	n := atomic.AddInt64(&m.inFlight, 1)
+	defer atomic.AddInt64(&m.inFlight, -1)
+	for max := atomic.LoadInt64(&m.maxInFlight); n > max; max = atomic.LoadInt64(&m.maxInFlight) {
+		if atomic.CompareAndSwapInt64(&m.maxInFlight, max, n) {
+			break
+		}
+	}
+
+	// let the responders find their way back to the transport, e.g. to use its clock, and get
+	// the parsed querystring, see MatchedQuery
+	ctx := context.WithValue(req.Context(), transportKey{}, m)
+	ctx = context.WithValue(ctx, queryKey{}, req.URL.Query())
+	usedUp := new(int32)
+	req = req.WithContext(context.WithValue(ctx, usedUpKey{}, usedUp))
+
+	received := now(req)
+	m.mu.Lock()
+	m.timestamps = append(m.timestamps, received)
+	transcript := m.transcript
+	m.mu.Unlock()
+
+	// buffer the body before the matchers and responders get a chance to consume it
+	rec, err := snapshotRequest(req, m.BufferRequestBodies)
+	if err != nil {
+		return nil, err
+	}
+	if m.BufferRequestBodies {
+		atomic.AddInt64(&m.requestBytes, int64(len(rec.body)))
+	} else if req.ContentLength > 0 {
+		atomic.AddInt64(&m.requestBytes, req.ContentLength)
+	}
+
+	var exhausted map[string]bool
+	for {
+		sel := m.responderForRequest(req, exhausted)
+
+		var resp *http.Response
+		atomic.StoreInt32(usedUp, 0)
+		if transcript != nil {
+			resp, err = m.transcribe(transcript, req, sel.responder)
+		} else {
+			resp, err = sel.responder(req)
+		}
+		if atomic.LoadInt32(usedUp) != 0 {
+			m.markUsedUp(sel)
+		}
+
+		// a responder used up or inactive (see Responder.Times and Responder.ActiveBetween) lets
+		// the request fall through
+		if fallsThrough(err) {
+			m.uncount(sel)
+			if exhausted == nil {
+				exhausted = make(map[string]bool)
+			}
+			exhausted[sel.id] = true
+			continue
+		}
+
+		if sel.key != "" {
+			m.storeRequest(sel.key, rec)
+		}
+		if !sel.matched {
+			m.storeUnmatchedRequest(rec)
+		}
+
+		if err == nil && resp != nil {
+			resp = m.withDefaultHeaders(req, resp)
+
+			// whatever the responder, a response to a HEAD request has no body
+			if req.Method == "HEAD" && resp.Body != nil && resp.Body != http.NoBody {
+				head := headResponse(resp)
+				resp.Body.Close()
+				resp = head
+			}
+		}
+		return resp, err
+	}
+}
+
+// selection is the responder chosen for a request by responderForRequest.
+type selection struct {
+	// id identifies where the responder was found, so that it can be skipped if it turns out to
+	// be exhausted or inactive.
+	id string
+	// key is the "METHOD URL" key the responder is registered with, empty if it isn't a keyed
+	// one.
+	key       string
+	responder Responder
+	// matched is false if no registered responder matched and the 'no responder' responder was
+	// chosen.
+	matched bool
+}
+
+// responderForRequest returns the responder which will handle req, counting the call.  Responders
+// whose id is in exhausted are skipped: the request was already counted by the first lookup,
+// so only the call to the responder finally chosen is counted, not the request.  If no registered
+// responder matches, the 'no responder' responder is returned and matched is false.
+func (m *MockTransport) responderForRequest(req *http.Request, exhausted map[string]bool) selection {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if exhausted == nil {
+		m.requestCount++
+		if m.expvarTotal != nil {
+			m.expvarTotal.Add(1)
+		}
+	}
+
+	skip := m.usedUp
+	if len(exhausted) > 0 {
+		skip = make(map[string]bool, len(m.usedUp)+len(exhausted))
+		for id := range m.usedUp {
+			skip[id] = true
+		}
+		for id := range exhausted {
+			skip[id] = true
+		}
+	}
+
+	sel := m.lookup(req, m.requestCount, skip)
+	switch {
+	case sel.key != "":
+		m.countCall(sel.key)
+	case strings.HasPrefix(sel.id, "matcher "):
+		m.totalCallCount++
+	}
+	return sel
+}
+
+// lookup returns the responder which handles req, the nth request received, skipping the ones
+// whose id is in exhausted.  Nothing is counted.  m.mu must be held, at least for reading.
+func (m *MockTransport) lookup(req *http.Request, n int, exhausted map[string]bool) selection {
+	// the requests beyond the limit are routed to onExceed, whatever their URL
+	if m.maxRequests > 0 && n > m.maxRequests && !exhausted["max"] {
+		return selection{id: "max", responder: m.onExceed, matched: true}
+	}
+
+	// the Nth request overall is routed to its hook, whatever its URL
+	if hook, ok := m.nthHooks[n]; ok && !exhausted["nth"] {
+		return selection{id: "nth", responder: hook, matched: true}
+	}
 
 	// try and get a responder that matches the method and URL
-	responder := m.responderForKey(req.Method + " " + url)
+	reqKey := req.Method + " " + normalizedURLString(req.URL)
+	key, responder := m.keyedResponder(reqKey, req, exhausted)
+
+	// then ignore the order of the querystring parameters, if asked to
+	if responder == nil && m.SortQueryParams && strings.Contains(reqKey, "&") {
+		sorted := sortQueryKey(reqKey)
+		for _, k := range m.responderKeys(func(k string) bool {
+			return !exhausted[k] && strings.Contains(k, "&") && sortQueryKey(k) == sorted
+		}) {
+			if responder = m.responderForKey(k, req); responder != nil {
+				key = k
+				break
+			}
+		}
+	}
 
 	// if we weren't able to find a responder and the URL contains a querystring
-	// then we strip off the querystring and try again.
-	if responder == nil && strings.Contains(url, "?") {
-		responder = m.responderForKey(req.Method + " " + strings.Split(url, "?")[0])
+	// then we try the querystring regexps, then strip off the querystring and try again.
+	if responder == nil && (req.URL.RawQuery != "" || req.URL.ForceQuery) {
+		noQuery := *req.URL
+		noQuery.RawQuery = ""
+		noQuery.ForceQuery = false
+		noQueryKey := req.Method + " " + normalizedURLString(&noQuery)
+
+		for _, qr := range m.queryRegexps {
+			k := qr.countKey()
+			if m.sameKey(qr.key, noQueryKey) && !exhausted[k] && qr.re.MatchString(req.URL.RawQuery) {
+				key, responder = k, qr.responder
+				break
+			}
+		}
+
+		if responder == nil {
+			key, responder = m.keyedResponder(noQueryKey, req, exhausted)
+		}
 	}
 
-	// if we found a responder, call it
 	if responder != nil {
-		return responder(req)
+		return selection{id: key, key: key, responder: responder, matched: true}
+	}
+
+	// then the path patterns
+	if key, responder = m.pathResponderForRequest(req, exhausted); responder != nil {
+		return selection{id: key, key: key, responder: responder, matched: true}
+	}
+
+	// then the prefix responders, the longest prefix winning
+	if key, responder = m.prefixResponderForKey(reqKey, exhausted); responder != nil {
+		return selection{id: "prefix " + key, key: key, responder: responder, matched: true}
+	}
+
+	// then try the matcher responders, in priority order
+	for i, mr := range m.matchers {
+		id := fmt.Sprintf("matcher %d", i)
+		if !exhausted[id] && mr.match(req) {
+			return selection{id: id, responder: mr.responder, matched: true}
+		}
+	}
+
+	// then the responder for any URL of the method
+	if key = req.Method + " " + anyURL; !exhausted[key] {
+		if responder = m.responderForKey(key, req); responder != nil {
+			return selection{id: key, key: key, responder: responder, matched: true}
+		}
+	}
+
+	// we didn't find a responder, so fire the 'no responder' responder of the method, if any
+	if responder, ok := m.noResponders[req.Method]; ok && !exhausted["none "+req.Method] {
+		return selection{id: "none " + req.Method, responder: responder}
+	}
+
+	// requests of pass-through methods reach the real network
+	if m.passThrough[req.Method] && !exhausted["passthrough"] {
+		return selection{id: "passthrough", responder: passThroughResponder, matched: true}
 	}
 
 	// we didn't find a responder, so fire the 'no responder' responder
-	if m.noResponder == nil {
-		return ConnectionFailure(req)
+	if m.noResponder == nil || exhausted["none"] {
+		return selection{id: "none", responder: ConnectionFailure}
+	}
+	return selection{id: "none", responder: m.noResponder}
+}
+
+// Match returns the responder which would handle req if it was sent now, without calling it nor
+// counting anything, to answer "what will happen to this request?".  The full matching logic
+// applies, including the nth request hooks (see SetNthRequestHook) for the next request.  If no
+// registered responder matches, the 'no responder' responder (ConnectionFailure by default) is
+// returned along with false.  As for the requests, the responders used up by Responder.Times are
+// skipped.
+//
+// The matchers registered with RegisterMatcherResponder are called, so they should be free of
+// side effects too.
+func (m *MockTransport) Match(req *http.Request) (Responder, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sel := m.lookup(req, m.requestCount+1, m.usedUp)
+	return sel.responder, sel.matched
+}
+
+// countCall counts a call to the responder registered for key.  m.mu must be held.
+func (m *MockTransport) countCall(key string) {
+	m.callCountInfo[key]++
+	m.totalCallCount++
+	m.callOrder = append(m.callOrder, key)
+	if m.expvarByKey != nil {
+		m.expvarByKey.Add(key, 1)
+	}
+}
+
+// markUsedUp remembers that the responder of sel is used up (see Responder.Times), so that Match
+// skips it.  The nth request hooks are not remembered, as each one only handles a single request
+// anyway.
+func (m *MockTransport) markUsedUp(sel selection) {
+	if sel.id == "nth" {
+		return
+	}
+
+	m.mu.Lock()
+	if m.usedUp == nil {
+		m.usedUp = make(map[string]bool)
+	}
+	m.usedUp[sel.id] = true
+	m.mu.Unlock()
+}
+
+// uncount reverts the counting of the call to the responder of sel, which turned out to be
+// exhausted or inactive.
+func (m *MockTransport) uncount(sel selection) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if sel.key != "" {
+		m.callCountInfo[sel.key]--
+		for i := len(m.callOrder) - 1; i >= 0; i-- {
+			if m.callOrder[i] == sel.key {
+				m.callOrder = append(m.callOrder[:i], m.callOrder[i+1:]...)
+				break
+			}
+		}
+		if m.expvarByKey != nil {
+			m.expvarByKey.Add(sel.key, -1)
+		}
+	}
+	if sel.matched && sel.id != "nth" && sel.id != "max" && sel.id != "passthrough" {
+		m.totalCallCount--
 	}
-	return m.noResponder(req)
 }
 
 // do nothing with timeout
 func (m *MockTransport) CancelRequest(req *http.Request) {}
 
-// responderForKey returns a responder for a given key
-func (m *MockTransport) responderForKey(key string) Responder {
+// keyedResponder returns the responder registered for key, along with the key it is registered
+// with, unless it is in exhausted.  If IgnorePort is true and none is registered for key, the
+// responders registered for the same key but another port, or none, are looked for.  m.mu must
+// be held, at least for reading.
+func (m *MockTransport) keyedResponder(key string, req *http.Request, exhausted map[string]bool) (string, Responder) {
+	if !exhausted[key] {
+		if responder := m.responderForKey(key, req); responder != nil {
+			return key, responder
+		}
+	}
+
+	if m.IgnorePort {
+		stripped := stripKeyPort(key)
+		for _, k := range m.responderKeys(func(k string) bool {
+			return k != key && !exhausted[k] && stripKeyPort(k) == stripped
+		}) {
+			if responder := m.responderForKey(k, req); responder != nil {
+				return k, responder
+			}
+		}
+	}
+	return "", nil
+}
+
+// responderKeys returns the keys of the responders registered with RegisterResponder for which
+// match returns true, sorted so that the lookups trying several of them are deterministic.
+func (m *MockTransport) responderKeys(match func(key string) bool) []string {
+	var keys []string
+	for k := range m.responders {
+		if match(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sameKey returns whether the keys a and b are the same, ignoring their ports if IgnorePort is
+// true.
+func (m *MockTransport) sameKey(a, b string) bool {
+	if a == b {
+		return true
+	}
+	return m.IgnorePort && stripKeyPort(a) == stripKeyPort(b)
+}
+
+// responderForKey returns a responder for a given key, unless it is disabled.  Responders
+// registered for the User-Agent of req take precedence.
+func (m *MockTransport) responderForKey(key string, req *http.Request) Responder {
+	if m.disabled[key] {
+		return nil
+	}
+	if len(m.uaResponders[key]) > 0 {
+		ua := req.Header.Get("User-Agent")
+		for _, r := range m.uaResponders[key] {
+			if strings.Contains(ua, r.substring) {
+				return r.responder
+			}
+		}
+	}
 	for k, r := range m.responders {
 		if k != key {
 			continue
@@ -74,26 +582,689 @@ func (m *MockTransport) responderForKey(key string) Responder {
 
 // RegisterResponder adds a new responder, associated with a given HTTP method and URL.  When a
 // request comes in that matches, the responder will be called and the response returned to the client.
-func (m *MockTransport) RegisterResponder(method, url string, responder Responder) {
-	m.responders[method+" "+url] = responder
+// Both the registered URL and the request URL are parsed and re-encoded, with their host
+// lower-cased, so that equivalent spellings of a URL (e.g. bracketed IPv6 hosts) match.  Fragments
+// are stripped, with a warning, as they are never part of a request.
+//
+// The scheme is part of the key, so responders registered for "http://x/" and "https://x/" are
+// distinct, whatever the lookup (querystring fallback, SortQueryParams...).  Only its case is
+// ignored.
+//
+// The special URL "*" registers a responder for any URL of the given method, see
+// RegisterResponderAnyURL.
+//
+// The MockTransport is returned so that registrations can be chained:
+//
+//	mock.RegisterResponder("GET", "http://example.com/a", responderA).
+//		RegisterResponder("GET", "http://example.com/b", responderB)
+func (m *MockTransport) RegisterResponder(method, url string, responder Responder) *MockTransport {
+	if strings.Contains(url, "#") {
+		Logger.Printf("httpmock: fragment of %s ignored, fragments are never sent to servers", url)
+	}
+	m.warnIfDisabled("responder for " + method + " " + url)
+
+	key := responderKey(method, url)
+	var site string
+	if m.RecordRegistrationSites {
+		site = callerSite()
+	}
+
+	m.mu.Lock()
+	m.responders[key] = responder
+	delete(m.usedUp, key)
+	if site != "" {
+		if m.sites == nil {
+			m.sites = make(map[string]string)
+		}
+		m.sites[key] = site
+	} else {
+		delete(m.sites, key)
+	}
+	m.mu.Unlock()
+	return m
+}
+
+// RegisterResponderBothSchemes registers responder for both the "http://" and "https://" variants
+// of hostPath, e.g. "example.com/articles", for code using either scheme depending on its
+// configuration.  It is the same as calling RegisterResponder for each of them, so calls are
+// counted separately for each scheme.
+func (m *MockTransport) RegisterResponderBothSchemes(method, hostPath string, responder Responder) {
+	m.RegisterResponder(method, "http://"+hostPath, responder)
+	m.RegisterResponder(method, "https://"+hostPath, responder)
+}
+
+// RegisterFromMap registers each responder of mocks for the method and URL given by its key, a
+// "METHOD URL" string as the ones of GetCallCountInfo, e.g.:
+//
+//	err := mock.RegisterFromMap(map[string]httpmock.Responder{
+//		"GET http://example.com/articles":  articlesResponder,
+//		"POST http://example.com/articles": createResponder,
+//	})
+//
+// Each key must be made of exactly a method and a URL separated by spaces, otherwise an error is
+// returned and nothing is registered.
+func (m *MockTransport) RegisterFromMap(mocks map[string]Responder) error {
+	keys := make([]string, 0, len(mocks))
+	for key := range mocks {
+		if len(strings.Fields(key)) != 2 {
+			return fmt.Errorf("key %q is not a \"METHOD URL\" string", key)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fields := strings.Fields(key)
+		m.RegisterResponder(fields[0], fields[1], mocks[key])
+	}
+	return nil
+}
+
+// SetDefaultResponseHeaders sets headers merged into every response to a request for host,
+// whatever the responder.  host is compared case-insensitively to the host of the request URL,
+// with or without its port.  Headers already set by the responder take precedence over h.
+func (m *MockTransport) SetDefaultResponseHeaders(host string, h http.Header) {
+	m.mu.Lock()
+	if m.defaultHeaders == nil {
+		m.defaultHeaders = make(map[string]http.Header)
+	}
+	m.defaultHeaders[strings.ToLower(host)] = h
+	m.mu.Unlock()
+}
+
+// withDefaultHeaders returns resp with the default headers set for the host of req, if any.
+func (m *MockTransport) withDefaultHeaders(req *http.Request, resp *http.Response) *http.Response {
+	m.mu.RLock()
+	h, ok := m.defaultHeaders[strings.ToLower(req.URL.Host)]
+	if !ok {
+		h, ok = m.defaultHeaders[strings.ToLower(req.URL.Hostname())]
+	}
+	m.mu.RUnlock()
+
+	if !ok {
+		return resp
+	}
+
+	resp = cloneResponse(resp)
+	for key, values := range h {
+		key = http.CanonicalHeaderKey(key)
+		if _, set := resp.Header[key]; !set {
+			resp.Header[key] = append([]string(nil), values...)
+		}
+	}
+	return resp
+}
+
+// DisableResponder makes the responder registered for the given HTTP method and URL be skipped,
+// as if it wasn't registered, until EnableResponder is called.  This simulates an endpoint going
+// down in the middle of a test without losing its definition.
+func (m *MockTransport) DisableResponder(method, url string) {
+	m.mu.Lock()
+	if m.disabled == nil {
+		m.disabled = make(map[string]bool)
+	}
+	m.disabled[responderKey(method, url)] = true
+	m.mu.Unlock()
+}
+
+// EnableResponder re-enables a responder disabled with DisableResponder.
+func (m *MockTransport) EnableResponder(method, url string) {
+	m.mu.Lock()
+	delete(m.disabled, responderKey(method, url))
+	m.mu.Unlock()
+}
+
+// RegisterMatcherResponder adds a responder which is called for any request for which match
+// returns true.  It is the same as RegisterMatcherResponderWithPriority with a priority of 0.
+func (m *MockTransport) RegisterMatcherResponder(match func(*http.Request) bool, responder Responder) {
+	m.RegisterMatcherResponderWithPriority(match, responder, 0)
+}
+
+// RegisterMatcherResponderWithPriority adds a responder which is called for any request for which
+// match returns true.  Matcher responders are only consulted when no responder registered for the
+// exact method and URL (see RegisterResponder) matches, so keyed matches always win.  Among matcher
+// responders, higher priorities are consulted first and equal priorities are consulted in
+// registration order.
+func (m *MockTransport) RegisterMatcherResponderWithPriority(match func(*http.Request) bool, responder Responder, priority int) {
+	m.warnIfDisabled("matcher responder")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mr := matcherResponder{match: match, responder: responder, priority: priority}
+
+	// insert after every matcher with a higher or equal priority
+	i := sort.Search(len(m.matchers), func(i int) bool {
+		return m.matchers[i].priority < priority
+	})
+	m.matchers = append(m.matchers, matcherResponder{})
+	copy(m.matchers[i+1:], m.matchers[i:])
+	m.matchers[i] = mr
+
+	// the matchers after it move by one, so do their used up ids
+	for j := len(m.matchers) - 1; j > i; j-- {
+		id := fmt.Sprintf("matcher %d", j)
+		if prev := fmt.Sprintf("matcher %d", j-1); m.usedUp[prev] {
+			m.usedUp[id] = true
+		} else {
+			delete(m.usedUp, id)
+		}
+	}
+	delete(m.usedUp, fmt.Sprintf("matcher %d", i))
+}
+
+// RegisterResponderWithUserAgent adds a responder for the given HTTP method and URL which is only
+// called when the User-Agent header of the request contains userAgentSubstring.  When several
+// are registered for the same method and URL, the first registered one matching wins.  If none
+// matches, the responder registered with RegisterResponder for the same method and URL, if any,
+// is used.  Calls are counted under the same "METHOD URL" key.
+func (m *MockTransport) RegisterResponderWithUserAgent(method, url, userAgentSubstring string, responder Responder) {
+	m.warnIfDisabled("responder for " + method + " " + url + " with User-Agent " + userAgentSubstring)
+
+	key := responderKey(method, url)
+
+	m.mu.Lock()
+	if m.uaResponders == nil {
+		m.uaResponders = make(map[string][]uaResponder)
+	}
+	m.uaResponders[key] = append(m.uaResponders[key], uaResponder{userAgentSubstring, responder})
+	delete(m.usedUp, key)
+	m.mu.Unlock()
+}
+
+// RegisterPrefixResponder adds a responder which is called for any request with the given HTTP
+// method whose URL starts with urlPrefix, e.g. "https://api.mybiz.com/api/v1/".  Prefix
+// responders are consulted after the exact URL (and querystring-less URL) and path responders and
+// before the matcher responders.  When several prefixes match, the longest one wins.  Calls are
+// counted under the "METHOD urlPrefix" key.
+func (m *MockTransport) RegisterPrefixResponder(method, urlPrefix string, responder Responder) {
+	m.warnIfDisabled("prefix responder for " + method + " " + urlPrefix)
+
+	m.mu.Lock()
+	if m.prefixes == nil {
+		m.prefixes = make(map[string]Responder)
+	}
+	key := responderKey(method, urlPrefix)
+	m.prefixes[key] = responder
+	delete(m.usedUp, "prefix "+key)
+	m.mu.Unlock()
+}
+
+// anyURL is the URL registering a responder for any URL, see RegisterResponderAnyURL.
+const anyURL = "*"
+
+// RegisterResponderAnyURL adds a responder for any request with the given HTTP method, e.g. to
+// make all GET requests return an empty list by default.  It is the same as calling
+// RegisterResponder with the "*" URL, and calls are counted under the "METHOD *" key.  It is
+// only consulted when no more specific responder matches, see RoundTrip for the precedence.
+func (m *MockTransport) RegisterResponderAnyURL(method string, responder Responder) {
+	m.RegisterResponder(method, anyURL, responder)
+}
+
+// RegisterQueryRegexpResponder adds a responder for the given HTTP method and URL, without
+// querystring, e.g. "https://api.mybiz.com/articles", which is only called when the raw
+// querystring of the request matches queryRegexp, e.g. `^page=\d+$`.  The regexp isn't anchored
+// unless it says so.
+//
+// Querystring regexp responders are consulted after the responder registered with
+// RegisterResponder for the full URL, querystring included, and before the one registered for
+// the URL without querystring.  Among them, the first registered matching one wins.  Calls are
+// counted under the "METHOD URL =~ REGEXP" key.
+func (m *MockTransport) RegisterQueryRegexpResponder(method, path string, queryRegexp *regexp.Regexp, responder Responder) {
+	m.warnIfDisabled("responder for " + method + " " + path + "?" + queryRegexp.String())
+
+	qr := queryRegexpResponder{
+		key:       responderKey(method, path),
+		re:        queryRegexp,
+		responder: responder,
+	}
+
+	m.mu.Lock()
+	m.queryRegexps = append(m.queryRegexps, qr)
+	delete(m.usedUp, qr.countKey())
+	m.mu.Unlock()
+}
+
+// RegexpResponders returns the querystring regexp responders registered with
+// RegisterQueryRegexpResponder, as "METHOD URL =~ REGEXP" keys, in the order they are tried,
+// which is their registration order.  This helps checking which of overlapping regexps wins.
+func (m *MockTransport) RegexpResponders() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]string, len(m.queryRegexps))
+	for i, qr := range m.queryRegexps {
+		keys[i] = qr.countKey()
+	}
+	return keys
+}
+
+// RegisterPassThroughMethod makes the requests with the given HTTP method which match no
+// registered responder go to InitialTransport, i.e. the real network, instead of the 'no
+// responder' responder.  Registered responders still handle the requests they match, whatever
+// their method, so only the unmatched requests of method pass through.  Requests passing through
+// are neither counted nor reported by UnmatchedRequests.
+func (m *MockTransport) RegisterPassThroughMethod(method string) {
+	m.mu.Lock()
+	if m.passThrough == nil {
+		m.passThrough = make(map[string]bool)
+	}
+	m.passThrough[method] = true
+	delete(m.usedUp, "passthrough")
+	m.mu.Unlock()
+}
+
+// passThroughResponder sends req to InitialTransport, see RegisterPassThroughMethod.
+func passThroughResponder(req *http.Request) (*http.Response, error) {
+	return InitialTransport.RoundTrip(req)
+}
+
+// prefixResponderForKey returns the responder registered with the longest prefix of key, along
+// with that prefix.  Disabled prefixes and those whose responder is in exhausted are skipped.
+// Ports are ignored if IgnorePort is true.
+func (m *MockTransport) prefixResponderForKey(key string, exhausted map[string]bool) (string, Responder) {
+	// longest is the registered prefix, longestP the form of it compared to key
+	var longest, longestP string
+	var responder Responder
+	if m.IgnorePort {
+		key = stripKeyPort(key)
+	}
+	for prefix, r := range m.prefixes {
+		p := prefix
+		if m.IgnorePort {
+			p = stripKeyPort(prefix)
+		}
+		if !strings.HasPrefix(key, p) || m.disabled[prefix] || exhausted["prefix "+prefix] {
+			continue
+		}
+
+		// the longest prefix wins, then the first one in lexical order, for determinism
+		if responder == nil || len(p) > len(longestP) ||
+			len(p) == len(longestP) && (p < longestP || p == longestP && prefix < longest) {
+			longest, longestP, responder = prefix, p, r
+		}
+	}
+	return longest, responder
 }
 
 // RegisterNoResponder is used to register a responder that will be called if no other responder is
 // found.  The default is ConnectionFailure.
 func (m *MockTransport) RegisterNoResponder(responder Responder) {
+	m.warnIfDisabled("no responder")
+
+	m.mu.Lock()
 	m.noResponder = responder
+	delete(m.usedUp, "none")
+	m.mu.Unlock()
 }
 
-// Reset removes all registered responders (including the no responder) from the MockTransport
+// RegisterNoResponderForMethod registers a responder called for the requests with the given
+// HTTP method which match no other responder, e.g. so that unmatched GET requests get a 404 while
+// unmatched POST requests fail.  It takes precedence over the pass-through methods and the 'no
+// responder' registered with RegisterNoResponder, see RoundTrip for the full precedence.  As
+// with RegisterNoResponder, the requests it handles are unmatched ones (see UnmatchedRequests).
+func (m *MockTransport) RegisterNoResponderForMethod(method string, responder Responder) {
+	m.warnIfDisabled("no responder for " + method)
+
+	m.mu.Lock()
+	if m.noResponders == nil {
+		m.noResponders = make(map[string]Responder)
+	}
+	m.noResponders[method] = responder
+	delete(m.usedUp, "none "+method)
+	m.mu.Unlock()
+}
+
+// Reset removes all registered responders (including the no responder) from the MockTransport.
+// Call counts, recorded and unmatched requests, hooks, the transcript, the concurrent requests
+// peak, the total request bytes and the store are cleared as well, and the streaming responders
+// still running are stopped (see StreamContext).
 func (m *MockTransport) Reset() {
+	m.mu.Lock()
 	m.responders = make(map[string]Responder)
+	m.matchers = nil
+	m.prefixes = nil
+	m.queryRegexps = nil
+	m.pathResponders = nil
+	m.passThrough = nil
+	m.sites = nil
+	m.uaResponders = nil
+	m.disabled = nil
+	m.defaultHeaders = nil
 	m.noResponder = nil
+	m.noResponders = nil
+	m.callCountInfo = make(map[string]int)
+	m.totalCallCount = 0
+	m.callOrder = nil
+	m.timestamps = nil
+	m.requestCount = 0
+	m.nthHooks = nil
+	m.maxRequests = 0
+	m.onExceed = nil
+	m.transcript = nil
+	m.lastRequests = nil
+	m.unmatched = nil
+	m.usedUp = nil
+	m.mu.Unlock()
+
+	atomic.StoreInt64(&m.maxInFlight, 0)
+	atomic.StoreInt64(&m.requestBytes, 0)
+	m.store.Range(func(k, _ interface{}) bool {
+		m.store.Delete(k)
+		return true
+	})
+	m.cancelStreams()
+}
+
+// TotalRequestBytes returns the cumulated size of the bodies of the requests received by the
+// MockTransport, matched or not, since its creation or the last Reset.  If BufferRequestBodies
+// is false, the bodies aren't read and their ContentLength is used instead, unknown lengths
+// counting for nothing.
+func (m *MockTransport) TotalRequestBytes() int64 {
+	return atomic.LoadInt64(&m.requestBytes)
+}
+
+// Store returns a key/value store shared by the responders of the MockTransport, to mock
+// stateful APIs: e.g. a POST responder stores the created object, which a GET responder then
+// returns.  Being a *sync.Map, it is safe for the concurrent use of responders handling
+// concurrent requests, but a read-modify-write sequence isn't atomic unless done with its
+// LoadOrStore, LoadAndDelete... methods.  Reset empties it; the returned pointer stays valid.
+func (m *MockTransport) Store() *sync.Map {
+	return &m.store
+}
+
+// streamContext returns the context of the streams served by m, cancelled by cancelStreams.
+func (m *MockTransport) streamContext() context.Context {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.streams == nil {
+		m.streams, m.stopStreams = context.WithCancel(context.Background())
+	}
+	return m.streams
+}
+
+// cancelStreams stops the streams currently served by m, see StreamContext.  Streams started
+// afterwards get a fresh context.
+func (m *MockTransport) cancelStreams() {
+	m.mu.Lock()
+	if m.stopStreams != nil {
+		m.stopStreams()
+		m.streams, m.stopStreams = nil, nil
+	}
+	m.mu.Unlock()
+}
+
+// MaxConcurrentRequests returns the highest number of requests the MockTransport handled at the
+// same time, i.e. of concurrent RoundTrip calls, since its creation or the last Reset.  The time
+// spent by the client reading the response bodies isn't taken into account.
+func (m *MockTransport) MaxConcurrentRequests() int {
+	return int(atomic.LoadInt64(&m.maxInFlight))
+}
+
+// PublishExpvar publishes the activity of the MockTransport through the expvar package, so it can
+// be observed on the standard /debug/vars endpoint during long-running tests.  Two variables are
+// published:
+//   - name, an expvar.Int counting every request received;
+//   - name + "_by_key", an expvar.Map counting the calls of each responder, keyed by "METHOD URL".
+//
+// Publishing a name again, e.g. from another transport of a later test, reuses the variables
+// already published, zeroing them, as expvar can't unpublish them.  It panics if the name is
+// already used by a variable of another type.  The counters are not cleared by Reset.
+func (m *MockTransport) PublishExpvar(name string) {
+	expvarMu.Lock()
+	if expvar.Get(name) == nil {
+		expvar.NewInt(name)
+	}
+	if expvar.Get(name+"_by_key") == nil {
+		expvar.NewMap(name + "_by_key")
+	}
+	total, okTotal := expvar.Get(name).(*expvar.Int)
+	byKey, okByKey := expvar.Get(name + "_by_key").(*expvar.Map)
+	expvarMu.Unlock()
+
+	if !okTotal || !okByKey {
+		panic("httpmock: expvar " + name + " already published with another type")
+	}
+	total.Set(0)
+	byKey.Init()
+
+	m.mu.Lock()
+	m.expvarTotal = total
+	m.expvarByKey = byKey
+	m.mu.Unlock()
+}
+
+// expvarMu serializes the publications of PublishExpvar, so that concurrent ones of the same name
+// don't both try to publish it.
+var expvarMu sync.Mutex
+
+// SetNthRequestHook routes the nth request (starting at 1) received by the MockTransport to
+// responder, regardless of its method and URL.  Every request reaching RoundTrip is counted,
+// matched or not.  This helps testing the resilience to a failure in the middle of a sequence
+// of calls.
+func (m *MockTransport) SetNthRequestHook(n int, responder Responder) {
+	m.mu.Lock()
+	if m.nthHooks == nil {
+		m.nthHooks = make(map[int]Responder)
+	}
+	m.nthHooks[n] = responder
+	m.mu.Unlock()
+}
+
+// SetMaxRequests limits the number of requests the MockTransport handles normally to n: the
+// requests beyond, matched or not, are routed to onExceed, regardless of their method and URL,
+// so that runaway retry loops are caught.  Every request reaching RoundTrip is counted, as for
+// SetNthRequestHook, and the limit takes precedence over the nth request hooks.  If onExceed is
+// nil, the extra requests fail with MaxRequestsExceeded.  A limit of 0 or less removes it.
+func (m *MockTransport) SetMaxRequests(n int, onExceed Responder) {
+	if onExceed == nil {
+		onExceed = func(*http.Request) (*http.Response, error) {
+			return nil, MaxRequestsExceeded
+		}
+	}
+
+	m.mu.Lock()
+	m.maxRequests = n
+	m.onExceed = onExceed
+	delete(m.usedUp, "max")
+	m.mu.Unlock()
+}
+
+// ResetCallCounts zeroes the call count of every responder as well as the total call count, and
+// clears the call order, but leaves the responders registered.  This allows asserting call
+// counts per phase of a test.
+func (m *MockTransport) ResetCallCounts() {
+	m.mu.Lock()
+	m.callCountInfo = make(map[string]int)
+	m.totalCallCount = 0
+	m.callOrder = nil
+	m.mu.Unlock()
+}
+
+// GetCallCountInfo returns a copy of the call count of every registered responder, keyed by
+// "METHOD URL".  Only requests which were routed to a registered responder are counted.
+func (m *MockTransport) GetCallCountInfo() map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := make(map[string]int, len(m.callCountInfo))
+	for k, v := range m.callCountInfo {
+		res[k] = v
+	}
+	return res
+}
+
+// CallCountDiff returns, for each key, how many more calls were counted than in baseline, a call
+// count info previously returned by GetCallCountInfo.  Keys missing from baseline count from
+// zero, and keys whose count didn't increase are omitted, so the result only holds the calls made
+// since baseline was taken.
+func (m *MockTransport) CallCountDiff(baseline map[string]int) map[string]int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	res := make(map[string]int)
+	for k, v := range m.callCountInfo {
+		if diff := v - baseline[k]; diff > 0 {
+			res[k] = diff
+		}
+	}
+	return res
+}
+
+// GetTotalCallCount returns the number of requests which were routed to a registered responder.
+func (m *MockTransport) GetTotalCallCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.totalCallCount
+}
+
+// CallOrder returns the "METHOD URL" keys of the responders called, in the order they were
+// called.  As for call counts, only requests routed to a responder registered for a method and
+// URL (or URL prefix) are recorded.
+func (m *MockTransport) CallOrder() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.callOrder...)
+}
+
+// RequestTimestamps returns the times the requests went through the transport, in that order,
+// as given by Now when they entered RoundTrip, whether they matched a responder or not.  Along
+// with CallOrder, this allows asserting that requests were spaced out, e.g. by a backoff.
+func (m *MockTransport) RequestTimestamps() []time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]time.Time(nil), m.timestamps...)
+}
+
+// AssertCalledInOrder returns an error unless the given "METHOD URL" keys appear in the call
+// order (see CallOrder) in this relative order.  They don't need to be adjacent, so incidental
+// requests made in between don't matter.
+func (m *MockTransport) AssertCalledInOrder(keys ...string) error {
+	order := m.CallOrder()
+
+	i := 0
+	for _, called := range order {
+		if i < len(keys) && called == normalizeKey(keys[i]) {
+			i++
+		}
+	}
+
+	switch {
+	case i == len(keys):
+		return nil
+	case i == 0:
+		return fmt.Errorf("expected %s to be called, call order was %v", keys[0], order)
+	default:
+		return fmt.Errorf("expected %s to be called after %s, call order was %v",
+			keys[i], strings.Join(keys[:i], ", "), order)
+	}
+}
+
+// normalizeKey normalizes the URL part of a "METHOD URL" key, see responderKey.
+func normalizeKey(key string) string {
+	parts := strings.SplitN(key, " ", 2)
+	if len(parts) != 2 {
+		return key
+	}
+	return responderKey(parts[0], parts[1])
+}
+
+// AssertNotCalled returns an error if the responder registered for the given HTTP method and URL
+// has been called at least once.  This is handy to verify that a caching layer avoided an
+// upstream call.
+func (m *MockTransport) AssertNotCalled(method, url string) error {
+	m.mu.RLock()
+	count := m.callCountInfo[responderKey(method, url)]
+	m.mu.RUnlock()
+
+	if count > 0 {
+		return fmt.Errorf("expected %s %s not to be called, but it was called %d time(s)", method, url, count)
+	}
+	return nil
+}
+
+// AssertAllRespondersCalled returns an error listing the responders registered with
+// RegisterResponder which have never been called.  If RecordRegistrationSites is set, each one
+// comes with the file:line it was registered at, so that dead mocks are easy to find.
+func (m *MockTransport) AssertAllRespondersCalled() error {
+	m.mu.RLock()
+	var uncalled []string
+	for key := range m.responders {
+		if m.callCountInfo[key] == 0 {
+			if site := m.sites[key]; site != "" {
+				key += " (registered at " + site + ")"
+			}
+			uncalled = append(uncalled, key)
+		}
+	}
+	m.mu.RUnlock()
+
+	if len(uncalled) > 0 {
+		sort.Strings(uncalled)
+		return fmt.Errorf("responders never called:\n\t%s", strings.Join(uncalled, "\n\t"))
+	}
+	return nil
+}
+
+// EqualRegistrations reports whether m and other have the same registrations, e.g. to check that
+// two mock setup helpers declare the same endpoints.  Only the keys the responders are registered
+// under are compared (method and URL, prefix, pattern or querystring regexp, user agent
+// substring, pass-through and 'no responder' methods), plus the number of matcher responders:
+// responders being functions, their behaviors can't be compared.
+func (m *MockTransport) EqualRegistrations(other *MockTransport) bool {
+	return reflect.DeepEqual(m.registrationKeys(), other.registrationKeys())
+}
+
+// registrationKeys returns the set of the keys of the registrations of m, see EqualRegistrations.
+func (m *MockTransport) registrationKeys() map[string]bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make(map[string]bool)
+	for key := range m.responders {
+		keys[key] = true
+	}
+	for key, responders := range m.uaResponders {
+		for _, r := range responders {
+			keys[key+" user agent "+r.substring] = true
+		}
+	}
+	for prefix := range m.prefixes {
+		keys["prefix "+prefix] = true
+	}
+	for _, qr := range m.queryRegexps {
+		keys[qr.countKey()] = true
+	}
+	for _, pr := range m.pathResponders {
+		keys["path "+pr.key] = true
+	}
+	for i := range m.matchers {
+		keys[fmt.Sprintf("matcher %d", i)] = true
+	}
+	for method := range m.passThrough {
+		keys["passthrough "+method] = true
+	}
+	for method := range m.noResponders {
+		keys["none "+method] = true
+	}
+	if m.noResponder != nil {
+		keys["none"] = true
+	}
+	return keys
 }
 
 // DefaultTransport is the default mock transport used by Activate, Deactivate, Reset,
 // DeactivateAndReset, RegisterResponder, and RegisterNoResponder.
 var DefaultTransport = NewMockTransport()
 
+// Transport returns DefaultTransport as an http.RoundTripper, to be injected in code which calls
+// RoundTrip directly on a transport it holds rather than going through http.DefaultTransport,
+// see Activate.  Requests sent to it are mocked whether or not Activate has been called.
+func Transport() http.RoundTripper {
+	return DefaultTransport
+}
+
 // InitialTransport is a cache of the original transport used so we can put it back
 // when Deactivate is called.
 var InitialTransport = http.DefaultTransport
@@ -102,27 +1273,51 @@ var InitialTransport = http.DefaultTransport
 var oldTransport http.RoundTripper
 var oldClient *http.Client
 
+// activation guards the activation state.  Every Activate/ActivateNonDefault increments
+// activations and every Deactivate decrements it; the real transports are only put back when
+// it drops to zero.
+var activation sync.Mutex
+var activations int
+
 // Activate starts the mock environment.  This should be called before your tests run.  Under the
 // hood this replaces the Transport on the http.DefaultClient with DefaultTransport.
 //
 // To enable mocks for a test, simply activate at the beginning of a test:
-// 		func TestFetchArticles(t *testing.T) {
-// 			httpmock.Activate()
-// 			// all http requests will now be intercepted
-// 		}
+//
+//	func TestFetchArticles(t *testing.T) {
+//		httpmock.Activate()
+//		// all http requests will now be intercepted
+//	}
 //
 // If you want all of your tests in a package to be mocked, just call Activate from init():
-// 		func init() {
-// 			httpmock.Activate()
-// 		}
+//
+//	func init() {
+//		httpmock.Activate()
+//	}
+//
+// Activate and Deactivate are reference counted, so nested pairs (e.g. in sequential subtests)
+// only restore the original transport when the outermost Deactivate is called.
+//
+// Only http.DefaultTransport is swapped, so requests are intercepted when they go through it,
+// either via http.DefaultClient or by calling http.DefaultTransport.RoundTrip at request time.
+// Code holding its own http.RoundTripper (e.g. an *http.Transport built at startup, or a copy of
+// http.DefaultTransport taken before Activate) bypasses the mocks; wire it to Transport() in
+// tests instead.
 func Activate() {
 	if Disabled() {
 		return
 	}
 
+	activation.Lock()
+	defer activation.Unlock()
+	activations++
+
+	// the mocks are live again: the next Deactivate restores the transports, not RestoreTransport
+	strictlyDeactivated = false
+
 	// make sure that if Activate is called multiple times it doesn't overwrite the InitialTransport
 	// with a mock transport.
-	if http.DefaultTransport != DefaultTransport {
+	if http.DefaultTransport != DefaultTransport && http.DefaultTransport != deactivated {
 		InitialTransport = http.DefaultTransport
 	}
 
@@ -134,39 +1329,208 @@ func Activate() {
 // http.DefaultTransport
 //
 // To enable mocks for a test using a custom client, activate at the beginning of a test:
-// 		client := &http.Client{Transport: &http.Transport{TLSHandshakeTimeout: 60 * time.Second}}
-// 		httpmock.ActivateNonDefault(client)
+//
+//	client := &http.Client{Transport: &http.Transport{TLSHandshakeTimeout: 60 * time.Second}}
+//	httpmock.ActivateNonDefault(client)
 func ActivateNonDefault(client *http.Client) {
 	if Disabled() {
 		return
 	}
 
-	// save the custom client & it's RoundTripper
-	oldTransport = client.Transport
+	activation.Lock()
+	defer activation.Unlock()
+	activations++
+
+	// the mocks are live again: the next Deactivate restores the transports, not RestoreTransport
+	strictlyDeactivated = false
+
+	// save the custom client & it's RoundTripper, unless it is already mocked
+	if client.Transport != DefaultTransport && client.Transport != deactivated {
+		oldTransport = client.Transport
+	}
 	oldClient = client
 	client.Transport = DefaultTransport
 }
 
+// Used to handle wrapped transports, see ActivateNonDefaultInner
+var oldInnerTransport reflect.Value
+var innerTransportField reflect.Value
+
+// ActivateNonDefaultInner starts the mock environment with a non-default http.Client whose
+// Transport wraps another RoundTripper, like golang.org/x/oauth2's Transport.  Instead of replacing
+// client.Transport, it walks the chain of wrapping transports and replaces the innermost one, so
+// the wrappers keep doing their job (e.g. adding an Authorization header) while the actual I/O is
+// mocked.
+//
+// A wrapping transport is a pointer to a struct with an exported field named Base or Transport of
+// type http.RoundTripper.  The walk stops at the first transport which isn't a wrapping one, or
+// at a wrapper whose field is nil, and that field is set to DefaultTransport.  If client.Transport
+// isn't a wrapping transport, this is the same as ActivateNonDefault.
+//
+// Deactivate puts the original inner transport back.
+func ActivateNonDefaultInner(client *http.Client) {
+	field := innermostTransportField(client.Transport)
+	if !field.IsValid() {
+		ActivateNonDefault(client)
+		return
+	}
+
+	if Disabled() {
+		return
+	}
+
+	activation.Lock()
+	defer activation.Unlock()
+	activations++
+
+	// the mocks are live again: the next Deactivate restores the transports, not RestoreTransport
+	strictlyDeactivated = false
+
+	if field.Interface() != DefaultTransport && field.Interface() != deactivated {
+		oldInnerTransport = reflect.ValueOf(field.Interface())
+	}
+	innerTransportField = field
+	field.Set(reflect.ValueOf(DefaultTransport))
+}
+
+// innermostTransportField returns the settable Base or Transport field holding the innermost
+// RoundTripper of the chain starting at rt, or an invalid value if rt isn't a wrapping transport.
+func innermostTransportField(rt http.RoundTripper) reflect.Value {
+	rtType := reflect.TypeOf((*http.RoundTripper)(nil)).Elem()
+
+	var field reflect.Value
+	// the depth limit protects against cycles
+	for depth := 0; rt != nil && depth < 32; depth++ {
+		v := reflect.ValueOf(rt)
+		if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			break
+		}
+
+		var next reflect.Value
+		for _, name := range []string{"Base", "Transport"} {
+			f := v.Elem().FieldByName(name)
+			if f.IsValid() && f.CanSet() && f.Type() == rtType {
+				next = f
+				break
+			}
+		}
+		if !next.IsValid() {
+			break
+		}
+
+		field = next
+		if next.IsNil() {
+			break
+		}
+		rt = next.Interface().(http.RoundTripper)
+	}
+	return field
+}
+
 // Deactivate shuts down the mock environment.  Any HTTP calls made after this will use a live
-// transport.
+// transport, unless another Activate call is still pending a matching Deactivate.  The streaming
+// responders of DefaultTransport still running are then stopped, see StreamContext.
 //
 // Usually you'll call it in a defer right after activating the mock environment:
-// 		func TestFetchArticles(t *testing.T) {
-// 			httpmock.Activate()
-// 			defer httpmock.Deactivate()
 //
-// 			// when this test ends, the mock environment will close
-// 		}
+//	func TestFetchArticles(t *testing.T) {
+//		httpmock.Activate()
+//		defer httpmock.Deactivate()
+//
+//		// when this test ends, the mock environment will close
+//	}
 func Deactivate() {
+	deactivate(false)
+}
+
+// DeactivateStrict shuts down the mock environment like Deactivate does, but instead of putting
+// the live transports back, it installs a transport failing every request with an error like
+// "httpmock deactivated: unexpected request to GET http://example.com/".  This catches goroutines
+// outliving a test and issuing requests to the real network.  The live transports are put back
+// by RestoreTransport, or by the next Deactivate.
+func DeactivateStrict() {
+	deactivate(true)
+}
+
+// RestoreTransport puts back the live transports after a DeactivateStrict.  It does nothing if
+// DeactivateStrict wasn't called, or if the mocks have been activated again since.
+func RestoreTransport() {
+	activation.Lock()
+	defer activation.Unlock()
+
+	if strictlyDeactivated && activations == 0 {
+		restoreTransports()
+	}
+}
+
+// deactivatedTransport is the transport installed by DeactivateStrict.
+type deactivatedTransport struct{}
+
+func (deactivatedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, fmt.Errorf("httpmock deactivated: unexpected request to %s %s", req.Method, req.URL)
+}
+
+// deactivated is the transport installed by DeactivateStrict.
+var deactivated http.RoundTripper = deactivatedTransport{}
+
+// strictlyDeactivated tells whether DeactivateStrict installed deactivated.
+var strictlyDeactivated bool
+
+func deactivate(strict bool) {
 	if Disabled() {
 		return
 	}
+
+	activation.Lock()
+	defer activation.Unlock()
+
+	if activations > 0 {
+		activations--
+	}
+	if activations > 0 {
+		// an outer Activate is still in effect
+		return
+	}
+
+	// the streams served by the mocks must not outlive them
+	DefaultTransport.cancelStreams()
+
+	if !strict {
+		restoreTransports()
+		return
+	}
+
+	http.DefaultTransport = deactivated
+	if oldClient != nil {
+		oldClient.Transport = deactivated
+	}
+	if innerTransportField.IsValid() {
+		innerTransportField.Set(reflect.ValueOf(deactivated))
+	}
+	strictlyDeactivated = true
+}
+
+// restoreTransports puts back the live transports.  activation must be held.
+func restoreTransports() {
 	http.DefaultTransport = InitialTransport
 
 	// reset the custom client to use it's original RoundTripper
 	if oldClient != nil {
 		oldClient.Transport = oldTransport
 	}
+
+	// and the wrapped transport to use it's original inner RoundTripper
+	if innerTransportField.IsValid() {
+		if oldInnerTransport.IsValid() {
+			innerTransportField.Set(oldInnerTransport)
+		} else {
+			innerTransportField.Set(reflect.Zero(innerTransportField.Type()))
+		}
+		innerTransportField = reflect.Value{}
+		oldInnerTransport = reflect.Value{}
+	}
+
+	strictlyDeactivated = false
 }
 
 // Reset will remove any registered mocks and return the mock environment to it's initial state.
@@ -185,30 +1549,190 @@ func DeactivateAndReset() {
 // route them to the Responder which will generate a response to be returned to the client.
 //
 // Example:
-// 		func TestFetchArticles(t *testing.T) {
-// 			httpmock.Activate()
-// 			httpmock.DeactivateAndReset()
 //
-// 			httpmock.RegisterResponder("GET", "http://example.com/",
-// 				httpmock.NewStringResponder("hello world", 200))
+//	func TestFetchArticles(t *testing.T) {
+//		httpmock.Activate()
+//		httpmock.DeactivateAndReset()
+//
+//		httpmock.RegisterResponder("GET", "http://example.com/",
+//			httpmock.NewStringResponder("hello world", 200))
 //
-//			// requests to http://example.com/ will now return 'hello world'
-// 		}
+//		// requests to http://example.com/ will now return 'hello world'
+//	}
 func RegisterResponder(method, url string, responder Responder) {
 	DefaultTransport.RegisterResponder(method, url, responder)
 }
 
+// RegisterResponderBothSchemes registers responder for both the http and https variants of
+// hostPath on DefaultTransport, see MockTransport.RegisterResponderBothSchemes.
+func RegisterResponderBothSchemes(method, hostPath string, responder Responder) {
+	DefaultTransport.RegisterResponderBothSchemes(method, hostPath, responder)
+}
+
+// RegisterFromMap registers the responders of mocks on DefaultTransport, see
+// MockTransport.RegisterFromMap.
+func RegisterFromMap(mocks map[string]Responder) error {
+	return DefaultTransport.RegisterFromMap(mocks)
+}
+
+// ResetCallCounts zeroes the call counts of DefaultTransport.
+func ResetCallCounts() {
+	DefaultTransport.ResetCallCounts()
+}
+
+// GetCallCountInfo returns the call count info of DefaultTransport.
+func GetCallCountInfo() map[string]int {
+	return DefaultTransport.GetCallCountInfo()
+}
+
+// TotalRequestBytes returns the cumulated size of the request bodies received by
+// DefaultTransport, see MockTransport.TotalRequestBytes.
+func TotalRequestBytes() int64 {
+	return DefaultTransport.TotalRequestBytes()
+}
+
+// Store returns the key/value store of DefaultTransport, see MockTransport.Store.
+func Store() *sync.Map {
+	return DefaultTransport.Store()
+}
+
+// MaxConcurrentRequests returns the highest number of concurrent requests handled by
+// DefaultTransport, see MockTransport.MaxConcurrentRequests.
+func MaxConcurrentRequests() int {
+	return DefaultTransport.MaxConcurrentRequests()
+}
+
+// CallCountDiff returns the calls counted by DefaultTransport since baseline, see
+// MockTransport.CallCountDiff.
+func CallCountDiff(baseline map[string]int) map[string]int {
+	return DefaultTransport.CallCountDiff(baseline)
+}
+
+// GetTotalCallCount returns the total call count of DefaultTransport.
+func GetTotalCallCount() int {
+	return DefaultTransport.GetTotalCallCount()
+}
+
+// CallOrder returns the call order of DefaultTransport.
+func CallOrder() []string {
+	return DefaultTransport.CallOrder()
+}
+
+// RequestTimestamps returns the request timestamps of DefaultTransport.
+func RequestTimestamps() []time.Time {
+	return DefaultTransport.RequestTimestamps()
+}
+
+// AssertCalledInOrder checks the call order of DefaultTransport, see
+// MockTransport.AssertCalledInOrder.
+func AssertCalledInOrder(keys ...string) error {
+	return DefaultTransport.AssertCalledInOrder(keys...)
+}
+
+// AssertNotCalled checks on DefaultTransport that the given HTTP method and URL were never called.
+func AssertNotCalled(method, url string) error {
+	return DefaultTransport.AssertNotCalled(method, url)
+}
+
+// AssertAllRespondersCalled checks on DefaultTransport that every responder was called, see
+// MockTransport.AssertAllRespondersCalled.
+func AssertAllRespondersCalled() error {
+	return DefaultTransport.AssertAllRespondersCalled()
+}
+
+// SetMaxRequests limits the number of requests DefaultTransport handles normally, see
+// MockTransport.SetMaxRequests.
+func SetMaxRequests(n int, onExceed Responder) {
+	DefaultTransport.SetMaxRequests(n, onExceed)
+}
+
+// SetNthRequestHook routes the nth request received by DefaultTransport to responder.
+func SetNthRequestHook(n int, responder Responder) {
+	DefaultTransport.SetNthRequestHook(n, responder)
+}
+
+// SetDefaultResponseHeaders sets default response headers for host on DefaultTransport, see
+// MockTransport.SetDefaultResponseHeaders.
+func SetDefaultResponseHeaders(host string, h http.Header) {
+	DefaultTransport.SetDefaultResponseHeaders(host, h)
+}
+
+// DisableResponder disables a responder of DefaultTransport, see MockTransport.DisableResponder.
+func DisableResponder(method, url string) {
+	DefaultTransport.DisableResponder(method, url)
+}
+
+// EnableResponder re-enables a responder of DefaultTransport disabled with DisableResponder.
+func EnableResponder(method, url string) {
+	DefaultTransport.EnableResponder(method, url)
+}
+
+// RegisterMatcherResponder adds a matcher responder on DefaultTransport, see
+// MockTransport.RegisterMatcherResponder.
+func RegisterMatcherResponder(match func(*http.Request) bool, responder Responder) {
+	DefaultTransport.RegisterMatcherResponder(match, responder)
+}
+
+// RegisterMatcherResponderWithPriority adds a matcher responder with the given priority on
+// DefaultTransport, see MockTransport.RegisterMatcherResponderWithPriority.
+func RegisterMatcherResponderWithPriority(match func(*http.Request) bool, responder Responder, priority int) {
+	DefaultTransport.RegisterMatcherResponderWithPriority(match, responder, priority)
+}
+
+// RegisterResponderWithUserAgent adds a User-Agent specific responder on DefaultTransport, see
+// MockTransport.RegisterResponderWithUserAgent.
+func RegisterResponderWithUserAgent(method, url, userAgentSubstring string, responder Responder) {
+	DefaultTransport.RegisterResponderWithUserAgent(method, url, userAgentSubstring, responder)
+}
+
+// RegisterPrefixResponder adds a prefix responder on DefaultTransport, see
+// MockTransport.RegisterPrefixResponder.
+func RegisterPrefixResponder(method, urlPrefix string, responder Responder) {
+	DefaultTransport.RegisterPrefixResponder(method, urlPrefix, responder)
+}
+
+// RegisterNoResponderForMethod registers the 'no responder' of the given HTTP method on
+// DefaultTransport, see MockTransport.RegisterNoResponderForMethod.
+func RegisterNoResponderForMethod(method string, responder Responder) {
+	DefaultTransport.RegisterNoResponderForMethod(method, responder)
+}
+
+// RegisterResponderAnyURL adds a responder for any URL of the given HTTP method on
+// DefaultTransport, see MockTransport.RegisterResponderAnyURL.
+func RegisterResponderAnyURL(method string, responder Responder) {
+	DefaultTransport.RegisterResponderAnyURL(method, responder)
+}
+
+// RegisterPassThroughMethod makes the unmatched requests with the given HTTP method go to the
+// real network, see MockTransport.RegisterPassThroughMethod.
+func RegisterPassThroughMethod(method string) {
+	DefaultTransport.RegisterPassThroughMethod(method)
+}
+
+// RegexpResponders returns the querystring regexp responders of DefaultTransport, see
+// MockTransport.RegexpResponders.
+func RegexpResponders() []string {
+	return DefaultTransport.RegexpResponders()
+}
+
+// RegisterQueryRegexpResponder adds a querystring regexp responder on DefaultTransport, see
+// MockTransport.RegisterQueryRegexpResponder.
+func RegisterQueryRegexpResponder(method, path string, queryRegexp *regexp.Regexp, responder Responder) {
+	DefaultTransport.RegisterQueryRegexpResponder(method, path, queryRegexp, responder)
+}
+
 // RegisterNoResponder adds a mock that will be called whenever a request for an unregistered URL
 // is received.  The default behavior is to return a connection error.
 //
 // In some cases you may not want all URLs to be mocked, in which case you can do this:
-// 		func TestFetchArticles(t *testing.T) {
-// 			httpmock.Activate()
-// 			httpmock.DeactivateAndReset()
-//			httpmock.RegisterNoResponder(httpmock.InitialTransport.RoundTrip)
 //
-// 			// any requests that don't have a registered URL will be fetched normally
-// 		}
+//	func TestFetchArticles(t *testing.T) {
+//		httpmock.Activate()
+//		httpmock.DeactivateAndReset()
+//		httpmock.RegisterNoResponder(httpmock.InitialTransport.RoundTrip)
+//
+//		// any requests that don't have a registered URL will be fetched normally
+//	}
 func RegisterNoResponder(responder Responder) {
 	DefaultTransport.RegisterNoResponder(responder)
 }
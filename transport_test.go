@@ -1,10 +1,21 @@
 package httpmock
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"regexp"
+	"runtime"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -184,3 +195,1458 @@ func TestMockTransportNonDefault(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+func TestMockTransportCallCount(t *testing.T) {
+	Reset()
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world"))
+
+	for i := 0; i < 2; i++ {
+		if _, err := http.Get(testUrl + "?i=1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if count := GetCallCountInfo()["GET "+testUrl]; count != 2 {
+		t.Fatalf("expected 2 calls, got %d", count)
+	}
+
+	if count := GetTotalCallCount(); count != 2 {
+		t.Fatalf("expected a total of 2 calls, got %d", count)
+	}
+
+	Reset()
+
+	if count := GetTotalCallCount(); count != 0 {
+		t.Fatalf("expected call count to be reset, got %d", count)
+	}
+}
+
+func TestMockTransportAssertNotCalled(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world"))
+
+	if err := AssertNotCalled("GET", testUrl); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := http.Get(testUrl); err != nil {
+		t.Fatal(err)
+	}
+
+	err := AssertNotCalled("GET", testUrl)
+	if err == nil {
+		t.Fatal("expected an error as the responder was called")
+	}
+
+	if !strings.Contains(err.Error(), "called 1 time") {
+		t.Fatalf("expected the error to contain the call count, got %q", err)
+	}
+}
+
+func TestMockTransportNestedActivate(t *testing.T) {
+	DeactivateAndReset()
+
+	initial := http.DefaultTransport
+
+	Activate()
+	Activate()
+
+	if http.DefaultTransport != DefaultTransport {
+		t.Fatal("expected http.DefaultTransport to be our DefaultTransport")
+	}
+
+	Deactivate()
+
+	if http.DefaultTransport != DefaultTransport {
+		t.Fatal("expected the outer Activate to still be in effect")
+	}
+
+	Deactivate()
+
+	if http.DefaultTransport != initial {
+		t.Fatal("expected http.DefaultTransport to be restored")
+	}
+
+	// an unbalanced Deactivate must not break the next activation
+	Deactivate()
+	Activate()
+	if http.DefaultTransport != DefaultTransport {
+		t.Fatal("expected http.DefaultTransport to be our DefaultTransport")
+	}
+	Deactivate()
+
+	if http.DefaultTransport != initial {
+		t.Fatal("expected http.DefaultTransport to be restored")
+	}
+}
+
+func TestMockTransportActivateSubtests(t *testing.T) {
+	DeactivateAndReset()
+
+	initial := http.DefaultTransport
+
+	Activate()
+	defer Reset()
+
+	for _, name := range []string{"first", "second", "third"} {
+		t.Run(name, func(t *testing.T) {
+			Activate()
+			defer Deactivate()
+
+			RegisterResponder("GET", testUrl+name, NewStringResponder(200, name))
+
+			resp, err := http.Get(testUrl + name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(data) != name {
+				t.Fatalf("expected body %q, got %q", name, data)
+			}
+		})
+
+		if http.DefaultTransport != DefaultTransport {
+			t.Fatal("expected the outer Activate to still be in effect")
+		}
+	}
+
+	Deactivate()
+
+	if http.DefaultTransport != initial {
+		t.Fatal("expected http.DefaultTransport to be restored")
+	}
+}
+
+func TestMockTransportNthRequestHook(t *testing.T) {
+	Reset()
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world"))
+	SetNthRequestHook(2, NewStringResponder(503, "unavailable"))
+
+	for i, expected := range []int{200, 503, 200} {
+		resp, err := http.Get(testUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != expected {
+			t.Fatalf("request #%d: expected status %d, got %d", i+1, expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestMockTransportMatcherPriority(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	always := func(*http.Request) bool { return true }
+
+	RegisterMatcherResponder(always, NewStringResponder(200, "first default"))
+	RegisterMatcherResponder(always, NewStringResponder(200, "second default"))
+	RegisterMatcherResponderWithPriority(always, NewStringResponder(200, "low"), -1)
+	RegisterMatcherResponderWithPriority(func(req *http.Request) bool {
+		return req.URL.Path == "/high"
+	}, NewStringResponder(200, "high"), 10)
+	RegisterResponder("GET", testUrl+"high/exact", NewStringResponder(200, "exact"))
+
+	tests := map[string]string{
+		testUrl + "high":       "high",
+		testUrl + "other":      "first default",
+		testUrl + "high/exact": "exact",
+	}
+
+	for u, expected := range tests {
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != expected {
+			t.Fatalf("%s: expected body %q, got %q", u, expected, data)
+		}
+	}
+}
+
+func TestMockTransportNilURL(t *testing.T) {
+	mock := NewMockTransport()
+
+	if _, err := mock.RoundTrip(&http.Request{Method: "GET"}); err != NilRequestURL {
+		t.Fatalf("expected NilRequestURL, got %v", err)
+	}
+}
+
+func TestMockTransportOpaqueURL(t *testing.T) {
+	mock := NewMockTransport()
+	mock.RegisterResponder("GET", "http://www.example.com/a%2Fb", NewStringResponder(200, "hello world"))
+
+	req := &http.Request{
+		Method: "GET",
+		URL:    &url.URL{Scheme: "http", Opaque: "//www.example.com/a%2Fb"},
+	}
+
+	resp, err := mock.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "hello world" {
+		t.Fatalf("expected body to be 'hello world', got %q", data)
+	}
+}
+
+func TestMockTransportChainedRegistration(t *testing.T) {
+	mock := NewMockTransport()
+	mock.RegisterResponder("GET", testUrl+"a", NewStringResponder(200, "a")).
+		RegisterResponder("GET", testUrl+"b", NewStringResponder(200, "b")).
+		RegisterResponder("POST", testUrl+"c", NewStringResponder(200, "c"))
+
+	client := &http.Client{Transport: mock}
+
+	for _, name := range []string{"a", "b", "c"} {
+		method := "GET"
+		if name == "c" {
+			method = "POST"
+		}
+
+		req, err := http.NewRequest(method, testUrl+name, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != name {
+			t.Fatalf("expected body %q, got %q", name, data)
+		}
+	}
+}
+
+func TestMockTransportDisableResponder(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world"))
+	RegisterNoResponder(NewStringResponder(503, "down"))
+
+	for _, step := range []struct {
+		toggle func(method, url string)
+		status int
+	}{
+		{nil, 200},
+		{DisableResponder, 503},
+		{EnableResponder, 200},
+	} {
+		if step.toggle != nil {
+			step.toggle("GET", testUrl)
+		}
+
+		resp, err := http.Get(testUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != step.status {
+			t.Fatalf("expected status %d, got %d", step.status, resp.StatusCode)
+		}
+	}
+}
+
+func TestMockTransportDefaultResponseHeaders(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	SetDefaultResponseHeaders("www.example.com", http.Header{
+		"Server":       {"nginx"},
+		"X-Request-Id": {"default"},
+	})
+
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world"))
+
+	overridden := NewStringResponse(200, "hello world")
+	overridden.Header.Set("X-Request-Id", "custom")
+	RegisterResponder("GET", testUrl+"custom", ResponderFromResponse(overridden))
+
+	RegisterResponder("GET", "http://other.example.com/", NewStringResponder(200, "hello world"))
+
+	tests := []struct {
+		url, server, requestID string
+	}{
+		{testUrl, "nginx", "default"},
+		{testUrl + "custom", "nginx", "custom"},
+		{"http://other.example.com/", "", ""},
+	}
+
+	for _, test := range tests {
+		resp, err := http.Get(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if v := resp.Header.Get("Server"); v != test.server {
+			t.Fatalf("%s: expected Server %q, got %q", test.url, test.server, v)
+		}
+
+		if v := resp.Header.Get("X-Request-Id"); v != test.requestID {
+			t.Fatalf("%s: expected X-Request-Id %q, got %q", test.url, test.requestID, v)
+		}
+	}
+}
+
+func TestMockTransportPrefixResponder(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterPrefixResponder("GET", testUrl+"api/", NewStringResponder(200, "api"))
+	RegisterPrefixResponder("GET", testUrl+"api/v1/", NewStringResponder(503, "maintenance"))
+	RegisterResponder("GET", testUrl+"api/v1/status", NewStringResponder(200, "status"))
+
+	tests := map[string]string{
+		testUrl + "api/v1/users/1":    "maintenance",
+		testUrl + "api/v1/users?p=2":  "maintenance",
+		testUrl + "api/v2/users":      "api",
+		testUrl + "api/v1/status":     "status",
+		testUrl + "api/v1/status?x=1": "status",
+	}
+
+	for u, expected := range tests {
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != expected {
+			t.Fatalf("%s: expected body %q, got %q", u, expected, data)
+		}
+	}
+
+	if _, err := http.Get(testUrl + "other"); err == nil {
+		t.Fatal("expected a URL outside of the prefixes not to match")
+	}
+
+	if count := GetCallCountInfo()["GET "+testUrl+"api/v1/"]; count != 2 {
+		t.Fatalf("expected 2 calls to the api/v1/ prefix, got %d", count)
+	}
+}
+
+func TestMockTransportResetCallCounts(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world"))
+
+	if _, err := http.Get(testUrl); err != nil {
+		t.Fatal(err)
+	}
+
+	ResetCallCounts()
+
+	if count := GetTotalCallCount(); count != 0 {
+		t.Fatalf("expected the total call count to be reset, got %d", count)
+	}
+
+	if count := GetCallCountInfo()["GET "+testUrl]; count != 0 {
+		t.Fatalf("expected the call count to be reset, got %d", count)
+	}
+
+	if _, err := http.Get(testUrl); err != nil {
+		t.Fatal("expected the responder to still be registered")
+	}
+
+	if count := GetCallCountInfo()["GET "+testUrl]; count != 1 {
+		t.Fatalf("expected 1 call after the reset, got %d", count)
+	}
+}
+
+func TestMockTransportCallCountDiff(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl+"a", NewStringResponder(200, "a"))
+	RegisterResponder("GET", testUrl+"b", NewStringResponder(200, "b"))
+
+	// phase 1
+	for _, u := range []string{"a", "a", "b"} {
+		if _, err := http.Get(testUrl + u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	baseline := GetCallCountInfo()
+
+	// phase 2, with a responder appearing after the baseline
+	RegisterResponder("GET", testUrl+"c", NewStringResponder(200, "c"))
+	for _, u := range []string{"a", "c", "c"} {
+		if _, err := http.Get(testUrl + u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diff := CallCountDiff(baseline)
+	expected := map[string]int{
+		"GET " + testUrl + "a": 1,
+		"GET " + testUrl + "c": 2,
+	}
+	if len(diff) != len(expected) {
+		t.Fatalf("expected diff %v, got %v", expected, diff)
+	}
+	for k, v := range expected {
+		if diff[k] != v {
+			t.Fatalf("expected diff %v, got %v", expected, diff)
+		}
+	}
+}
+
+// authTransport mimics golang.org/x/oauth2's Transport.
+type authTransport struct {
+	Base http.RoundTripper
+}
+
+func (a *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer token")
+	return a.Base.RoundTrip(req)
+}
+
+func TestMockTransportNonDefaultInner(t *testing.T) {
+	inner := &http.Transport{}
+	wrapper := &authTransport{Base: inner}
+	client := &http.Client{Transport: wrapper}
+
+	ActivateNonDefaultInner(client)
+	defer Reset()
+
+	RegisterResponder("GET", testUrl, NewRequireHeadersResponder([]string{"Authorization"},
+		NewStringResponder(200, "hello world")))
+
+	resp, err := client.Get(testUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected the Authorization header to reach the responder, got status %d", resp.StatusCode)
+	}
+
+	if client.Transport != wrapper {
+		t.Fatal("expected the wrapping transport to be kept")
+	}
+
+	Deactivate()
+
+	if wrapper.Base != inner {
+		t.Fatal("expected the inner transport to be restored")
+	}
+}
+
+func TestDeactivateStrict(t *testing.T) {
+	DeactivateAndReset()
+
+	initial := http.DefaultTransport
+
+	Activate()
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world"))
+	DeactivateStrict()
+	defer Reset()
+
+	_, err := http.Get(testUrl)
+	if err == nil || !strings.Contains(err.Error(), "httpmock deactivated: unexpected request to GET "+testUrl) {
+		t.Fatalf("expected a deactivation error, got %v", err)
+	}
+
+	// re-activating must not consider the strict transport as the live one
+	Activate()
+	if _, err := http.Get(testUrl); err != nil {
+		t.Fatal(err)
+	}
+	DeactivateStrict()
+
+	RestoreTransport()
+
+	if http.DefaultTransport != initial {
+		t.Fatal("expected http.DefaultTransport to be restored")
+	}
+}
+
+func TestRestoreTransportAfterReactivation(t *testing.T) {
+	DeactivateAndReset()
+
+	Activate()
+	DeactivateStrict()
+	defer Reset()
+
+	// activated again: RestoreTransport must not unmock http.DefaultTransport
+	Activate()
+	defer DeactivateAndReset()
+	RestoreTransport()
+
+	if http.DefaultTransport != DefaultTransport {
+		t.Fatal("expected http.DefaultTransport to still be mocked")
+	}
+
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world"))
+	if _, err := http.Get(testUrl); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMockTransportUserAgentResponder(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponderWithUserAgent("GET", testUrl, "mysdk/2.", NewStringResponder(200, "v2"))
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "any"))
+
+	tests := map[string]string{
+		"mysdk/2.1 (linux)": "v2",
+		"mysdk/1.9 (linux)": "any",
+		"":                  "any",
+	}
+
+	for ua, expected := range tests {
+		req, err := http.NewRequest("GET", testUrl, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("User-Agent", ua)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != expected {
+			t.Fatalf("User-Agent %q: expected body %q, got %q", ua, expected, data)
+		}
+	}
+}
+
+func TestMockTransportAssertCalledInOrder(t *testing.T) {
+	Reset()
+	Activate()
+	defer DeactivateAndReset()
+
+	for _, name := range []string{"login", "fetch", "logout"} {
+		RegisterResponder("GET", testUrl+name, NewStringResponder(200, name))
+	}
+
+	for _, name := range []string{"login", "fetch", "fetch", "logout"} {
+		if _, err := http.Get(testUrl + name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if order := CallOrder(); len(order) != 4 || order[3] != "GET "+testUrl+"logout" {
+		t.Fatalf("unexpected call order %v", order)
+	}
+
+	if err := AssertCalledInOrder("GET "+testUrl+"login", "GET "+testUrl+"logout"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := AssertCalledInOrder("GET "+testUrl+"logout", "GET "+testUrl+"login"); err == nil {
+		t.Fatal("expected an error as logout was called after login")
+	}
+
+	if err := AssertCalledInOrder("GET "+testUrl+"login", "GET "+testUrl+"other"); err == nil {
+		t.Fatal("expected an error as other was never called")
+	}
+
+	err := AssertCalledInOrder("GET "+testUrl+"other", "GET "+testUrl+"login")
+	if err == nil || strings.Contains(err.Error(), "after") {
+		t.Fatalf("expected an error not mentioning a previous call, got %v", err)
+	}
+}
+
+func TestMockTransportPublishExpvar(t *testing.T) {
+	mock := NewMockTransport()
+	mock.PublishExpvar("httpmock_test_requests")
+	mock.RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world"))
+
+	client := &http.Client{Transport: mock}
+	client.Get(testUrl)
+	client.Get(testUrl)
+	client.Get(testUrl + "unmatched")
+
+	if v := expvar.Get("httpmock_test_requests").String(); v != "3" {
+		t.Fatalf("expected 3 requests, got %s", v)
+	}
+
+	byKey := expvar.Get("httpmock_test_requests_by_key").(*expvar.Map)
+	if v := byKey.Get("GET " + testUrl); v == nil || v.String() != "2" {
+		t.Fatalf("expected 2 calls to GET %s, got %v", testUrl, v)
+	}
+
+	// publishing the same name again, e.g. from a later test, reuses and zeroes the variables
+	other := NewMockTransport()
+	other.PublishExpvar("httpmock_test_requests")
+	other.RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world"))
+	(&http.Client{Transport: other}).Get(testUrl)
+
+	if v := expvar.Get("httpmock_test_requests").String(); v != "1" {
+		t.Fatalf("expected 1 request after publishing again, got %s", v)
+	}
+
+	if expvar.Get("httpmock_test_string") == nil {
+		expvar.NewString("httpmock_test_string")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic for a name published with another type")
+		}
+	}()
+	NewMockTransport().PublishExpvar("httpmock_test_string")
+}
+
+func TestTransportRoundTripDirectly(t *testing.T) {
+	defer Reset()
+
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "direct"))
+
+	// no Activate: the transport is used as a plain http.RoundTripper
+	var transport http.RoundTripper = Transport()
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "direct" {
+		t.Fatalf("expected body %q, got %q", "direct", data)
+	}
+}
+
+func TestRegisterQueryRegexpResponder(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterQueryRegexpResponder("GET", testUrl+"articles", regexp.MustCompile(`^page=\d+$`),
+		func(req *http.Request) (*http.Response, error) {
+			return NewStringResponse(200, "page "+req.URL.Query().Get("page")), nil
+		})
+	RegisterResponder("GET", testUrl+"articles?page=last", NewStringResponder(200, "last page"))
+	RegisterResponder("GET", testUrl+"articles", NewStringResponder(200, "all"))
+
+	tests := map[string]string{
+		testUrl + "articles?page=1":    "page 1",
+		testUrl + "articles?page=42":   "page 42",
+		testUrl + "articles?page=last": "last page",
+		testUrl + "articles?page=1a":   "all",
+		testUrl + "articles":           "all",
+	}
+
+	for u, expected := range tests {
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatalf("%s: %s", u, err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != expected {
+			t.Fatalf("%s: expected body %q, got %q", u, expected, data)
+		}
+	}
+
+	if count := GetCallCountInfo()["GET "+testUrl+`articles =~ ^page=\d+$`]; count != 2 {
+		t.Fatalf("expected 2 calls, got %d", count)
+	}
+}
+
+func TestMockTransportMaxConcurrentRequests(t *testing.T) {
+	const workers = 5
+
+	mock := NewMockTransport()
+	client := &http.Client{Transport: mock}
+
+	// each request waits for every worker to be in flight
+	var inFlight sync.WaitGroup
+	inFlight.Add(workers)
+	mock.RegisterResponder("GET", testUrl, func(req *http.Request) (*http.Response, error) {
+		inFlight.Done()
+		inFlight.Wait()
+		return NewStringResponse(200, "ok"), nil
+	})
+
+	var done sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			if _, err := client.Get(testUrl); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	done.Wait()
+
+	if max := mock.MaxConcurrentRequests(); max != workers {
+		t.Fatalf("expected a peak of %d concurrent requests, got %d", workers, max)
+	}
+
+	// sequential requests don't raise it
+	mock.Reset()
+	mock.RegisterResponder("GET", testUrl, NewStringResponder(200, "ok"))
+	if max := mock.MaxConcurrentRequests(); max != 0 {
+		t.Fatalf("expected Reset to clear the peak, got %d", max)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.Get(testUrl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if max := mock.MaxConcurrentRequests(); max != 1 {
+		t.Fatalf("expected a peak of 1 concurrent request, got %d", max)
+	}
+}
+
+func TestMockTransportStore(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	type article struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	}
+
+	RegisterResponder("POST", testUrl+"articles", func(req *http.Request) (*http.Response, error) {
+		var a article
+		if err := json.NewDecoder(req.Body).Decode(&a); err != nil {
+			return NewStringResponse(400, err.Error()), nil
+		}
+		Store().Store(a.ID, a)
+		return NewJsonResponse(201, a)
+	})
+	RegisterPathResponder("GET", testUrl+"articles/:id",
+		func(params map[string]string, req *http.Request) (*http.Response, error) {
+			a, ok := Store().Load(params["id"])
+			if !ok {
+				return NewStringResponse(404, ""), nil
+			}
+			return NewJsonResponse(200, a)
+		})
+
+	if resp, err := http.Get(testUrl + "articles/1"); err != nil || resp.StatusCode != 404 {
+		t.Fatalf("expected a 404 before the creation, got %v, %v", resp, err)
+	}
+
+	resp, err := http.Post(testUrl+"articles", "application/json",
+		strings.NewReader(`{"id":"1","title":"My Great Article"}`))
+	if err != nil || resp.StatusCode != 201 {
+		t.Fatalf("expected a 201, got %v, %v", resp, err)
+	}
+
+	resp, err = http.Get(testUrl + "articles/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var a article
+	if err := json.NewDecoder(resp.Body).Decode(&a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Title != "My Great Article" {
+		t.Fatalf("unexpected article %+v", a)
+	}
+
+	Reset()
+	if _, ok := Store().Load("1"); ok {
+		t.Fatal("expected Reset to empty the store")
+	}
+}
+
+func TestRegisterPassThroughMethod(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("real " + r.Method))
+	}))
+	defer server.Close()
+
+	// make sure a live transport gets saved as InitialTransport
+	defer func(rt http.RoundTripper) { http.DefaultTransport = rt }(http.DefaultTransport)
+	http.DefaultTransport = server.Client().Transport
+
+	Reset()
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", server.URL+"/articles", NewStringResponder(200, "mocked GET"))
+	RegisterResponder("POST", server.URL+"/mocked", NewStringResponder(200, "mocked POST"))
+	RegisterPassThroughMethod("POST")
+
+	tests := []struct {
+		method, path, expected string
+	}{
+		{"GET", "/articles", "mocked GET"},
+		{"POST", "/articles", "real POST"},
+		{"POST", "/mocked", "mocked POST"},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, server.URL+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %s", test.method, test.path, err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != test.expected {
+			t.Fatalf("%s %s: expected body %q, got %q", test.method, test.path, test.expected, data)
+		}
+	}
+
+	// other methods don't pass through
+	req, err := http.NewRequest("PUT", server.URL+"/articles", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.DefaultClient.Do(req); err == nil {
+		t.Fatal("expected PUT not to pass through")
+	}
+
+	if count := GetTotalCallCount(); count != 2 {
+		t.Fatalf("expected 2 mocked calls, got %d", count)
+	}
+}
+
+func TestMockTransportAssertAllRespondersCalled(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	DefaultTransport.RecordRegistrationSites = true
+	defer func() { DefaultTransport.RecordRegistrationSites = false }()
+
+	RegisterResponder("GET", testUrl+"called", NewStringResponder(200, "called"))
+	RegisterResponder("GET", testUrl+"dead", NewStringResponder(200, "dead")) // dead mock
+	_, _, line, _ := runtime.Caller(0)
+
+	if _, err := http.Get(testUrl + "called"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := AssertAllRespondersCalled()
+	if err == nil {
+		t.Fatal("expected an error for the dead mock")
+	}
+
+	site := fmt.Sprintf("transport_test.go:%d", line-1)
+	if !strings.Contains(err.Error(), "GET "+testUrl+"dead") || !strings.Contains(err.Error(), site) {
+		t.Fatalf("expected the report to mention the dead mock and %s, got %q", site, err)
+	}
+	if strings.Contains(err.Error(), "called ") {
+		t.Fatalf("expected the called responder not to be reported, got %q", err)
+	}
+
+	if _, err := http.Get(testUrl + "dead"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertAllRespondersCalled(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterResponderAnyURL(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponderAnyURL("GET", NewStringResponder(200, "[]"))
+	RegisterResponder("GET", testUrl+"articles", NewStringResponder(200, "articles"))
+	RegisterPrefixResponder("GET", testUrl+"api/", NewStringResponder(200, "api"))
+	RegisterResponder("POST", "*", NewStringResponder(201, "created"))
+
+	tests := []struct {
+		method, url, expected string
+	}{
+		{"GET", testUrl + "articles", "articles"},
+		{"GET", testUrl + "api/users", "api"},
+		{"GET", testUrl + "anything?page=2", "[]"},
+		{"GET", "https://other.example.com/", "[]"},
+		{"POST", testUrl + "articles", "created"},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %s", test.method, test.url, err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != test.expected {
+			t.Fatalf("%s %s: expected body %q, got %q", test.method, test.url, test.expected, data)
+		}
+	}
+
+	// other methods still reach the no responder
+	req, err := http.NewRequest("DELETE", testUrl+"articles", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := http.DefaultClient.Do(req); err == nil {
+		t.Fatal("expected no responder for DELETE")
+	}
+
+	if count := GetCallCountInfo()["GET *"]; count != 2 {
+		t.Fatalf("expected 2 calls for any URL, got %d", count)
+	}
+}
+
+func matchedResponder(*http.Request) (*http.Response, error) {
+	return NewStringResponse(200, "matched"), nil
+}
+
+func TestMockTransportMatch(t *testing.T) {
+	mock := NewMockTransport()
+	mock.RegisterResponder("GET", testUrl+"articles", matchedResponder)
+	mock.RegisterMatcherResponder(func(req *http.Request) bool {
+		return req.Method == "DELETE"
+	}, NewStringResponder(204, ""))
+
+	req, err := http.NewRequest("GET", testUrl+"articles?page=2", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responder, ok := mock.Match(req)
+	if !ok {
+		t.Fatal("expected the request to match")
+	}
+	if reflect.ValueOf(responder).Pointer() != reflect.ValueOf(matchedResponder).Pointer() {
+		t.Fatal("expected the responder registered for the URL")
+	}
+
+	if req, err = http.NewRequest("DELETE", testUrl+"articles", nil); err != nil {
+		t.Fatal(err)
+	}
+	if responder, ok = mock.Match(req); !ok {
+		t.Fatal("expected the matcher responder to match")
+	}
+	if resp, err := responder(req); err != nil || resp.StatusCode != 204 {
+		t.Fatalf("expected the matcher responder, got %v, %v", resp, err)
+	}
+
+	if req, err = http.NewRequest("POST", testUrl+"articles", nil); err != nil {
+		t.Fatal(err)
+	}
+	if responder, ok = mock.Match(req); ok {
+		t.Fatal("expected no responder to match")
+	}
+	if _, err := responder(req); err != NoResponderFound {
+		t.Fatalf("expected the no responder, got %v", err)
+	}
+
+	// nothing was counted
+	if total := mock.GetTotalCallCount(); total != 0 {
+		t.Fatalf("expected no call to be counted, got %d", total)
+	}
+	if len(mock.CallOrder()) != 0 {
+		t.Fatalf("expected an empty call order, got %v", mock.CallOrder())
+	}
+}
+
+func TestMockTransportMatchTimes(t *testing.T) {
+	mock := NewMockTransport()
+	mock.RegisterResponder("GET", testUrl+"articles", NewStringResponder(200, "once").Times(1))
+	mock.RegisterResponder("GET", testUrl+"users", NewStringResponder(200, "once").Times(1))
+	mock.RegisterPrefixResponder("GET", testUrl, NewStringResponder(200, "prefix"))
+
+	matchBody := func(u string) (string, bool) {
+		req, err := http.NewRequest("GET", u, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		responder, ok := mock.Match(req)
+		resp, err := responder(req)
+		if err != nil {
+			return err.Error(), ok
+		}
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data), ok
+	}
+
+	if body, ok := matchBody(testUrl + "articles"); !ok || body != "once" {
+		t.Fatalf("expected the Times responder before any call, got %q, %t", body, ok)
+	}
+
+	req, err := http.NewRequest("GET", testUrl+"articles", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mock.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+
+	// used up, the request would fall through to the prefix responder
+	if body, ok := matchBody(testUrl + "articles"); !ok || body != "prefix" {
+		t.Fatalf("expected the prefix responder once used up, got %q, %t", body, ok)
+	}
+	if body, ok := matchBody(testUrl + "users"); !ok || body != "once" {
+		t.Fatalf("expected the other Times responder to be untouched, got %q, %t", body, ok)
+	}
+
+	// registering another responder in place of the used up one makes it match again
+	mock.RegisterResponder("GET", testUrl+"articles", NewStringResponder(200, "again"))
+	if body, ok := matchBody(testUrl + "articles"); !ok || body != "again" {
+		t.Fatalf("expected the new responder, got %q, %t", body, ok)
+	}
+}
+
+func TestMockTransportMatchTimesNoResponder(t *testing.T) {
+	mock := NewMockTransport()
+	mock.RegisterResponder("GET", testUrl, NewStringResponder(200, "once").Times(1))
+
+	req, err := http.NewRequest("GET", testUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mock.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+
+	responder, ok := mock.Match(req)
+	if ok {
+		t.Fatal("expected no responder to match once the Times responder is used up")
+	}
+	if _, err := responder(req); err != NoResponderFound {
+		t.Fatalf("expected the no responder, got %v", err)
+	}
+
+	mock.Reset()
+	mock.RegisterResponder("GET", testUrl, NewStringResponder(200, "once").Times(1))
+	if _, ok := mock.Match(req); !ok {
+		t.Fatal("expected Reset to forget the used up responders")
+	}
+}
+
+func TestMockTransportSetMaxRequests(t *testing.T) {
+	Reset()
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "ok"))
+	SetMaxRequests(3, nil)
+
+	// unmatched requests count too
+	http.Get(testUrl + "unknown")
+	for i := 0; i < 2; i++ {
+		if _, err := http.Get(testUrl); err != nil {
+			t.Fatalf("request #%d: %s", i+2, err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := http.Get(testUrl)
+		if !errors.Is(err, MaxRequestsExceeded) {
+			t.Fatalf("request #%d: expected MaxRequestsExceeded, got %v", i+4, err)
+		}
+	}
+
+	if count := GetCallCountInfo()["GET "+testUrl]; count != 2 {
+		t.Fatalf("expected the extra requests not to reach the responder, got %d calls", count)
+	}
+
+	// with a custom responder
+	Reset()
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "ok"))
+	SetMaxRequests(1, NewStringResponder(429, "too many"))
+
+	for i, expected := range []int{200, 429} {
+		resp, err := http.Get(testUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != expected {
+			t.Fatalf("request #%d: expected status %d, got %d", i+1, expected, resp.StatusCode)
+		}
+	}
+}
+
+func TestRegisterNoResponderForMethod(t *testing.T) {
+	Reset()
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl+"articles", NewStringResponder(200, "articles"))
+	RegisterNoResponderForMethod("GET", NewStringResponder(404, "not found"))
+	RegisterNoResponderForMethod("POST", func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("unexpected POST")
+	})
+	RegisterNoResponder(NewStringResponder(501, "global"))
+
+	tests := []struct {
+		method, path string
+		status       int
+		err          string
+	}{
+		{"GET", "articles", 200, ""},
+		{"GET", "unknown", 404, ""},
+		{"POST", "articles", 0, "unexpected POST"},
+		{"PUT", "articles", 501, ""},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, testUrl+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("%s %s: expected error %q, got %v", test.method, test.path, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != test.status {
+			t.Fatalf("%s %s: expected status %d, got %d", test.method, test.path, test.status, resp.StatusCode)
+		}
+	}
+
+	if n := len(UnmatchedRequests()); n != 3 {
+		t.Fatalf("expected 3 unmatched requests, got %d", n)
+	}
+}
+
+func TestMatchedQuery(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	var query url.Values
+	RegisterQueryRegexpResponder("GET", testUrl+"articles", regexp.MustCompile(`page=`),
+		func(req *http.Request) (*http.Response, error) {
+			if _, ok := req.Context().Value(queryKey{}).(url.Values); !ok {
+				t.Error("expected the parsed query in the request context")
+			}
+			query = MatchedQuery(req)
+			return NewStringResponse(200, "page "+query.Get("page")), nil
+		})
+
+	if _, err := http.Get(testUrl + "articles?page=3&tag=a&tag=b"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := url.Values{"page": {"3"}, "tag": {"a", "b"}}
+	if !reflect.DeepEqual(query, expected) {
+		t.Fatalf("expected %v, got %v", expected, query)
+	}
+
+	// outside of a MockTransport, the query is parsed on demand
+	req, err := http.NewRequest("GET", testUrl+"articles?page=4", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := MatchedQuery(req).Get("page"); got != "4" {
+		t.Fatalf("expected page 4, got %q", got)
+	}
+}
+
+func TestMockTransportEqualRegistrations(t *testing.T) {
+	setup := func(m *MockTransport, responder Responder) {
+		m.RegisterResponder("GET", testUrl+"articles", responder)
+		m.RegisterPrefixResponder("GET", testUrl+"static/", responder)
+		m.RegisterQueryRegexpResponder("GET", testUrl+"search", regexp.MustCompile(`^q=`), responder)
+		m.RegisterNoResponderForMethod("POST", responder)
+	}
+
+	a, b := NewMockTransport(), NewMockTransport()
+	setup(a, NewStringResponder(200, "a"))
+	setup(b, NewStringResponder(404, "b"))
+
+	if !a.EqualRegistrations(b) || !b.EqualRegistrations(a) {
+		t.Fatal("expected the registrations to be equal, whatever the responders")
+	}
+	if !a.EqualRegistrations(a) {
+		t.Fatal("expected a transport to have the same registrations as itself")
+	}
+
+	b.RegisterResponder("DELETE", testUrl+"articles", NewStringResponder(204, ""))
+	if a.EqualRegistrations(b) {
+		t.Fatal("expected the registrations to differ by an extra responder")
+	}
+
+	a.RegisterResponder("DELETE", testUrl+"articles/", NewStringResponder(204, ""))
+	if a.EqualRegistrations(b) {
+		t.Fatal("expected the registrations to differ by URL")
+	}
+
+	if !NewMockTransport().EqualRegistrations(NewMockTransport()) {
+		t.Fatal("expected two empty transports to have the same registrations")
+	}
+}
+
+func TestRegisterResponderBothSchemes(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponderBothSchemes("GET", "www.example.com/articles", NewStringResponder(200, "articles"))
+
+	for _, scheme := range []string{"http", "https"} {
+		u := scheme + "://www.example.com/articles"
+
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatalf("%s: %s", u, err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "articles" {
+			t.Fatalf("%s: expected body %q, got %q", u, "articles", data)
+		}
+
+		if count := GetCallCountInfo()["GET "+u]; count != 1 {
+			t.Fatalf("%s: expected 1 call, got %d", u, count)
+		}
+	}
+}
+
+func TestMockTransportRequestTimestamps(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2016, 12, 10, 0, 0, 0, 0, time.UTC)}
+
+	mock := NewMockTransport()
+	clock.install(mock)
+	mock.RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world"))
+
+	client := &http.Client{Transport: mock}
+
+	start := clock.Now()
+	if _, err := client.Get(testUrl); err != nil {
+		t.Fatal(err)
+	}
+	clock.Sleep(context.Background(), 2*time.Second)
+	if _, err := client.Get(testUrl + "unknown"); err == nil {
+		t.Fatal("expected an error for an unmatched request")
+	}
+
+	expected := []time.Time{start, start.Add(2 * time.Second)}
+	if got := mock.RequestTimestamps(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected timestamps %v, got %v", expected, got)
+	}
+
+	mock.Reset()
+	if got := mock.RequestTimestamps(); len(got) != 0 {
+		t.Fatalf("expected no timestamps after Reset, got %v", got)
+	}
+}
+
+func TestRegisterFromMap(t *testing.T) {
+	Reset()
+	Activate()
+	defer DeactivateAndReset()
+
+	err := RegisterFromMap(map[string]Responder{
+		"GET " + testUrl + "articles":      NewStringResponder(200, "articles"),
+		"POST " + testUrl + "articles":     NewStringResponder(201, "created"),
+		"DELETE " + testUrl + "articles/1": NewStringResponder(204, ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method, path string
+		status       int
+	}{
+		{"GET", "articles", 200},
+		{"POST", "articles", 201},
+		{"DELETE", "articles/1", 204},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, testUrl+test.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %s", test.method, test.path, err)
+		}
+		if resp.StatusCode != test.status {
+			t.Fatalf("%s %s: expected status %d, got %d", test.method, test.path, test.status, resp.StatusCode)
+		}
+	}
+
+	if total := GetTotalCallCount(); total != 3 {
+		t.Fatalf("expected 3 calls, got %d", total)
+	}
+}
+
+func TestRegisterFromMapMalformedKey(t *testing.T) {
+	mock := NewMockTransport()
+
+	for _, key := range []string{testUrl, "GET", "GET " + testUrl + " extra", ""} {
+		err := mock.RegisterFromMap(map[string]Responder{
+			"GET " + testUrl + "articles": NewStringResponder(200, "articles"),
+			key:                           NewStringResponder(200, "malformed"),
+		})
+		if err == nil {
+			t.Fatalf("expected an error for key %q", key)
+		}
+
+		if !mock.EqualRegistrations(NewMockTransport()) {
+			t.Fatalf("expected nothing registered for key %q", key)
+		}
+	}
+}
+
+func TestRegexpResponders(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterQueryRegexpResponder("GET", testUrl+"articles", regexp.MustCompile(`page=\d+`),
+		NewStringResponder(200, "numbered page"))
+	RegisterQueryRegexpResponder("GET", testUrl+"articles", regexp.MustCompile(`page=`),
+		NewStringResponder(200, "any page"))
+
+	expected := []string{
+		"GET " + testUrl + `articles =~ page=\d+`,
+		"GET " + testUrl + "articles =~ page=",
+	}
+	if got := RegexpResponders(); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+
+	// both regexps match, the first registered wins
+	resp, err := http.Get(testUrl + "articles?page=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "numbered page" {
+		t.Fatalf("expected the first regexp to win, got %q", data)
+	}
+}
+
+func TestMockTransportAssertAllRespondersCalledNoSites(t *testing.T) {
+	mock := NewMockTransport()
+	mock.RegisterResponder("GET", testUrl+"dead", NewStringResponder(200, "dead"))
+
+	err := mock.AssertAllRespondersCalled()
+	if err == nil {
+		t.Fatal("expected an error for the dead mock")
+	}
+	if strings.Contains(err.Error(), "registered at") {
+		t.Fatalf("expected no registration site unless recorded, got %q", err)
+	}
+}
+
+func TestQueryRegexpResponderKeyAssertions(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+	DefaultTransport.BufferRequestBodies = true
+	defer func() { DefaultTransport.BufferRequestBodies = false }()
+
+	RegisterQueryRegexpResponder("POST", testUrl+"articles", regexp.MustCompile(`^page=\d+$`),
+		NewStringResponder(200, "page"))
+
+	if _, err := http.Post(testUrl+"articles?page=1", "text/plain", strings.NewReader("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	key := "POST " + testUrl + `articles =~ ^page=\d+$`
+	if count := GetCallCountInfo()[key]; count != 1 {
+		t.Fatalf("expected 1 call under %q, got %d", key, count)
+	}
+
+	if err := AssertNotCalled("POST", testUrl+`articles =~ ^page=\d+$`); err == nil {
+		t.Fatal("expected AssertNotCalled to fail for the called regexp responder")
+	}
+
+	if err := AssertCalledInOrder(CallOrder()[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := AssertCalledInOrder(key); err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := RequestBody("POST", testUrl+`articles =~ ^page=\d+$`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello" {
+		t.Fatalf("expected the recorded body %q, got %q", "hello", body)
+	}
+
+	DefaultTransport.Verify(t, CalledExactly("POST", testUrl+`articles =~ ^page=\d+$`, 1))
+}
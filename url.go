@@ -0,0 +1,132 @@
+package httpmock
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// normalizeURL returns the canonical form of rawURL used to build responder keys, so that a
+// URL given at registration time and the URL of an incoming request compare equal.  See
+// normalizedURLString for the applied rules.  If rawURL can't be parsed it is returned
+// untouched.
+func normalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return normalizedURLString(u)
+}
+
+// queryRegexpSeparator separates the URL from the regexp in the keys of the querystring regexp
+// responders, see RegisterQueryRegexpResponder.
+const queryRegexpSeparator = " =~ "
+
+// responderKey returns the "METHOD URL" key the responder registered for method and url is known
+// under, for the registration as well as the call counts, the recorded requests and the
+// assertions.  url is normalized, see normalizeURL, except for the regexp part of the keys of
+// the querystring regexp responders, "URL =~ REGEXP", which is kept as is.  Applied to the URL
+// part of a key already built, it returns the key unchanged.
+func responderKey(method, url string) string {
+	if i := strings.Index(url, queryRegexpSeparator); i >= 0 {
+		return method + " " + normalizeURL(url[:i]) + url[i:]
+	}
+	return method + " " + normalizeURL(url)
+}
+
+// normalizedURLString is the same as normalizeURL for an already parsed URL:
+//   - the host, including bracketed IPv6 literals, is lower-cased;
+//   - each path segment is decoded then re-encoded, so "a%20b", "a+b" and "a b" are all the same
+//     segment, as are "%7E" and "~", while an encoded slash ("%2F") stays distinct from "/";
+//   - each querystring name and value is decoded then re-encoded, keeping their order;
+//   - the fragment is dropped, as it is never sent to servers.
+//
+// u is not modified.
+func normalizedURLString(u *url.URL) string {
+	c := *u
+	c.Host = strings.ToLower(c.Host)
+	c.Fragment = ""
+	c.RawFragment = ""
+
+	if c.Opaque == "" {
+		if p, ok := normalizePath(c.EscapedPath()); ok {
+			c.RawPath = p
+			c.Path, _ = url.PathUnescape(p)
+		}
+	}
+
+	if c.RawQuery != "" {
+		c.RawQuery = normalizeQuery(c.RawQuery)
+	}
+	return c.String()
+}
+
+// normalizePath re-encodes each segment of the escaped path p in a canonical way.  A literal "+"
+// is considered as an encoded space.
+func normalizePath(p string) (string, bool) {
+	segments := strings.Split(p, "/")
+	for i, seg := range segments {
+		unescaped, err := url.PathUnescape(strings.Replace(seg, "+", "%20", -1))
+		if err != nil {
+			return "", false
+		}
+		escaped := (&url.URL{Path: unescaped}).EscapedPath()
+		escaped = strings.Replace(escaped, "/", "%2F", -1)
+		segments[i] = strings.Replace(escaped, "+", "%2B", -1)
+	}
+	return strings.Join(segments, "/"), true
+}
+
+// normalizeQuery re-encodes each name and value of the raw querystring q in a canonical way,
+// keeping their order.  Parts which can't be decoded are left untouched.
+func normalizeQuery(q string) string {
+	pairs := strings.Split(q, "&")
+	for i, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		for j, part := range kv {
+			if unescaped, err := url.QueryUnescape(part); err == nil {
+				kv[j] = url.QueryEscape(unescaped)
+			}
+		}
+		pairs[i] = strings.Join(kv, "=")
+	}
+	return strings.Join(pairs, "&")
+}
+
+// sortQueryKey returns key, a normalized "METHOD URL" key, with its querystring parameters sorted
+// by name.  Parameters sharing a name keep their relative order.
+func sortQueryKey(key string) string {
+	idx := strings.Index(key, "?")
+	if idx < 0 {
+		return key
+	}
+
+	pairs := strings.Split(key[idx+1:], "&")
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return strings.SplitN(pairs[i], "=", 2)[0] < strings.SplitN(pairs[j], "=", 2)[0]
+	})
+	return key[:idx+1] + strings.Join(pairs, "&")
+}
+
+// stripKeyPort returns key, a normalized "METHOD URL" key, without the port of its URL.
+func stripKeyPort(key string) string {
+	parts := strings.SplitN(key, " ", 2)
+	if len(parts) != 2 {
+		return key
+	}
+
+	u, err := url.Parse(parts[1])
+	if err != nil || u.Port() == "" {
+		return key
+	}
+	u.Host = stripPort(u.Host)
+	return parts[0] + " " + u.String()
+}
+
+// stripPort returns host without its port, keeping the brackets of IPv6 literals.
+func stripPort(host string) string {
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.HasSuffix(host, "]") {
+		return host[:i]
+	}
+	return host
+}
@@ -0,0 +1,363 @@
+package httpmock
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestMockTransportIPv6(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	urls := []string{
+		"http://[::1]:8080/path",
+		"http://[::1]/path",
+		"http://[FE80::1]/upper",
+	}
+
+	for _, u := range urls {
+		RegisterResponder("GET", u, NewStringResponder(200, u))
+	}
+
+	for _, u := range urls {
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != u {
+			t.Fatalf("expected body %q, got %q", u, data)
+		}
+	}
+
+	if count := GetCallCountInfo()["GET http://[fe80::1]/upper"]; count != 1 {
+		t.Fatalf("expected the normalized key to be called once, got %d", count)
+	}
+}
+
+func TestMockTransportPercentEncoding(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	tests := []struct {
+		registered string
+		requested  []string
+	}{
+		{
+			registered: "http://www.example.com/a%20b",
+			requested:  []string{"http://www.example.com/a+b", "http://www.example.com/a%20b"},
+		},
+		{
+			registered: "http://www.example.com/c+d",
+			requested:  []string{"http://www.example.com/c%20d"},
+		},
+		{
+			registered: "http://www.example.com/~user/%2Fslash",
+			requested:  []string{"http://www.example.com/%7Euser/%2fslash"},
+		},
+		{
+			registered: "http://www.example.com/search?q=a+b&tag=%3Ax",
+			requested:  []string{"http://www.example.com/search?q=a%20b&tag=:x"},
+		},
+	}
+
+	for _, test := range tests {
+		RegisterResponder("GET", test.registered, NewStringResponder(200, test.registered))
+	}
+
+	for _, test := range tests {
+		for _, u := range test.requested {
+			resp, err := http.Get(u)
+			if err != nil {
+				t.Fatalf("%s: %s", u, err)
+			}
+
+			data, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(data) != test.registered {
+				t.Fatalf("%s: expected to match %q, got %q", u, test.registered, data)
+			}
+		}
+	}
+
+	// an encoded slash is not a path separator
+	if _, err := http.Get("http://www.example.com/~user//slash"); err == nil {
+		t.Fatal("expected /~user//slash not to match /~user/%2Fslash")
+	}
+}
+
+func TestMockTransportFragment(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl+"page#section", NewStringResponder(200, "hello world"))
+
+	resp, err := http.Get(testUrl + "page")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "hello world" {
+		t.Fatalf("expected body to be 'hello world', got %q", data)
+	}
+}
+
+func TestMockTransportQuerystringFallbackEncoded(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl+"what%3F", NewStringResponder(200, "encoded"))
+	RegisterResponder("GET", testUrl+"what", NewStringResponder(200, "plain"))
+
+	tests := map[string]string{
+		testUrl + "what%3F?hello=world": "encoded",
+		testUrl + "what?hello=world":    "plain",
+		testUrl + "what?":               "plain",
+	}
+
+	for u, expected := range tests {
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatalf("%s: %s", u, err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != expected {
+			t.Fatalf("%s: expected body %q, got %q", u, expected, data)
+		}
+	}
+}
+
+func TestMockTransportSortQueryParams(t *testing.T) {
+	mock := NewMockTransport()
+	mock.SortQueryParams = true
+	mock.RegisterResponder("GET", testUrl+"search?a=1&b=2&c=3", NewStringResponder(200, "sorted"))
+	client := &http.Client{Transport: mock}
+
+	for _, query := range []string{"a=1&b=2&c=3", "b=2&a=1&c=3", "c=3&b=2&a=1"} {
+		resp, err := client.Get(testUrl + "search?" + query)
+		if err != nil {
+			t.Fatalf("%s: %s", query, err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != "sorted" {
+			t.Fatalf("%s: expected body %q, got %q", query, "sorted", data)
+		}
+	}
+
+	// counted for the registered key
+	if count := mock.GetCallCountInfo()["GET "+testUrl+"search?a=1&b=2&c=3"]; count != 3 {
+		t.Fatalf("expected 3 calls, got %d", count)
+	}
+
+	// a different value is still a different request
+	if _, err := client.Get(testUrl + "search?b=2&a=2&c=3"); err == nil {
+		t.Fatal("expected no responder to match")
+	}
+
+	// without SortQueryParams, the order matters
+	mock.SortQueryParams = false
+	if _, err := client.Get(testUrl + "search?b=2&a=1&c=3"); err == nil {
+		t.Fatal("expected no responder to match")
+	}
+}
+
+func TestMockTransportScheme(t *testing.T) {
+	mock := NewMockTransport()
+	mock.SortQueryParams = true
+	mock.RegisterResponder("GET", "http://x/", NewStringResponder(200, "http"))
+	mock.RegisterResponder("GET", "https://x/", NewStringResponder(200, "https"))
+	mock.RegisterResponder("GET", "http://x/q?a=1&b=2", NewStringResponder(200, "http query"))
+	mock.RegisterResponder("GET", "HTTPS://x/q?a=1&b=2", NewStringResponder(200, "https query"))
+	client := &http.Client{Transport: mock}
+
+	tests := map[string]string{
+		"http://x/":            "http",
+		"https://x/":           "https",
+		"http://x/?foo=bar":    "http",
+		"https://x/?foo=bar":   "https",
+		"http://x/q?b=2&a=1":   "http query",
+		"https://x/q?b=2&a=1":  "https query",
+		"HTTP://x/q?a=1&b=2":   "http query",
+		"https://x/q?a=1&b=2":  "https query",
+		"https://X/q?a=1&b=2#": "https query",
+	}
+
+	for u, expected := range tests {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatalf("%s: %s", u, err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != expected {
+			t.Fatalf("%s: expected body %q, got %q", u, expected, data)
+		}
+	}
+
+	if _, err := client.Get("ftp://x/"); err == nil {
+		t.Fatal("expected no responder for another scheme")
+	}
+}
+
+func TestMockTransportIgnorePort(t *testing.T) {
+	mock := NewMockTransport()
+	mock.IgnorePort = true
+	mock.RegisterResponder("GET", "http://127.0.0.1/users", NewStringResponder(200, "portless"))
+	mock.RegisterResponder("GET", "http://127.0.0.1:8080/orders", NewStringResponder(200, "8080"))
+	mock.RegisterResponder("GET", "http://127.0.0.1:54321/users?id=1", NewStringResponder(200, "exact port"))
+	mock.RegisterPrefixResponder("GET", "http://[::1]/api/", NewStringResponder(200, "prefix"))
+	client := &http.Client{Transport: mock}
+
+	tests := map[string]string{
+		"http://127.0.0.1:54321/users":      "portless",
+		"http://127.0.0.1:54321/users?id=2": "portless",
+		"http://127.0.0.1:54321/users?id=1": "exact port",
+		"http://127.0.0.1/orders":           "8080",
+		"http://127.0.0.1:9/orders":         "8080",
+		"http://[::1]:54321/api/v1":         "prefix",
+	}
+
+	for u, expected := range tests {
+		resp, err := client.Get(u)
+		if err != nil {
+			t.Fatalf("%s: %s", u, err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != expected {
+			t.Fatalf("%s: expected body %q, got %q", u, expected, data)
+		}
+	}
+
+	// counted under the registered URL
+	if count := mock.GetCallCountInfo()["GET http://127.0.0.1/users"]; count != 2 {
+		t.Fatalf("expected 2 calls, got %d", count)
+	}
+
+	// the host still matters
+	if _, err := client.Get("http://127.0.0.2:54321/users"); err == nil {
+		t.Fatal("expected no responder for another host")
+	}
+
+	mock.IgnorePort = false
+	if _, err := client.Get("http://127.0.0.1:54321/users"); err == nil {
+		t.Fatal("expected the port to matter without IgnorePort")
+	}
+}
+
+func TestMockTransportSortQueryParamsSeveralMatches(t *testing.T) {
+	mock := NewMockTransport()
+	mock.SortQueryParams = true
+	mock.RegisterResponder("GET", testUrl+"search?c=3&b=2&a=1", NewStringResponder(200, "cba"))
+	mock.RegisterResponder("GET", testUrl+"search?a=1&b=2&c=3", NewStringResponder(200, "abc"))
+	client := &http.Client{Transport: mock}
+
+	// both match, the first in lexical order always wins
+	for i := 0; i < 20; i++ {
+		resp, err := client.Get(testUrl + "search?b=2&a=1&c=3")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(data) != "abc" {
+			t.Fatalf("call #%d: expected body %q, got %q", i+1, "abc", data)
+		}
+	}
+}
+
+func TestMockTransportIgnorePortSeveralMatches(t *testing.T) {
+	mock := NewMockTransport()
+	mock.IgnorePort = true
+	mock.RegisterResponder("GET", "http://127.0.0.1:9000/users", NewStringResponder(200, "9000"))
+	mock.RegisterResponder("GET", "http://127.0.0.1:8080/users", NewStringResponder(200, "8080"))
+	mock.RegisterResponder("GET", "http://127.0.0.1:9999/users", NewStringResponder(200, "exact port"))
+	mock.RegisterPrefixResponder("GET", "http://127.0.0.1:9000/api/", NewStringResponder(200, "prefix 9000"))
+	mock.RegisterPrefixResponder("GET", "http://127.0.0.1:8080/api/", NewStringResponder(200, "prefix 8080"))
+	mock.RegisterPrefixResponder("GET", "http://127.0.0.1/api/v2/", NewStringResponder(200, "prefix v2"))
+	client := &http.Client{Transport: mock}
+
+	tests := map[string]string{
+		"http://127.0.0.1:9999/users":  "exact port",
+		"http://127.0.0.1:1234/users":  "8080",
+		"http://127.0.0.1/users":       "8080",
+		"http://127.0.0.1:1234/api/v1": "prefix 8080",
+		"http://127.0.0.1:1/api/v2/x":  "prefix v2",
+	}
+
+	// the first registered URL in lexical order always wins
+	for i := 0; i < 20; i++ {
+		for u, expected := range tests {
+			resp, err := client.Get(u)
+			if err != nil {
+				t.Fatalf("%s: %s", u, err)
+			}
+
+			data, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(data) != expected {
+				t.Fatalf("%s: expected body %q, got %q", u, expected, data)
+			}
+		}
+	}
+}
+
+func TestResponderKey(t *testing.T) {
+	tests := map[string]string{
+		"http://WWW.Example.com/a%7Eb?x=a+b": "GET http://www.example.com/a~b?x=a+b",
+		testUrl + `articles =~ ^page=\d+ x$`: "GET " + testUrl + `articles =~ ^page=\d+ x$`,
+		"http://Example.com/users/:id":       "GET http://example.com/users/:id",
+		"*":                                  "GET *",
+	}
+
+	for url, expected := range tests {
+		key := responderKey("GET", url)
+		if key != expected {
+			t.Fatalf("%s: expected key %q, got %q", url, expected, key)
+		}
+
+		// an already built key is left unchanged
+		if again := normalizeKey(key); again != key {
+			t.Fatalf("%s: expected %q to be left unchanged, got %q", url, key, again)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package httpmock
+
+import (
+	"fmt"
+	"testing"
+)
+
+// CountMode tells how the call count of an Expectation is compared.
+type CountMode int
+
+const (
+	// Exactly expects the responder to be called exactly Count times.
+	Exactly CountMode = iota
+	// AtLeast expects the responder to be called Count times or more.
+	AtLeast
+	// AtMost expects the responder to be called Count times or less.
+	AtMost
+)
+
+// Expectation describes how many times the responder registered for an HTTP method and URL is
+// expected to be called, see MockTransport.Verify.
+type Expectation struct {
+	Method string
+	URL    string
+	Count  int
+	Mode   CountMode
+}
+
+// CalledExactly returns an Expectation of exactly n calls to method and url.
+func CalledExactly(method, url string, n int) Expectation {
+	return Expectation{Method: method, URL: url, Count: n, Mode: Exactly}
+}
+
+// CalledAtLeast returns an Expectation of n calls or more to method and url.
+func CalledAtLeast(method, url string, n int) Expectation {
+	return Expectation{Method: method, URL: url, Count: n, Mode: AtLeast}
+}
+
+// CalledAtMost returns an Expectation of n calls or less to method and url.
+func CalledAtMost(method, url string, n int) Expectation {
+	return Expectation{Method: method, URL: url, Count: n, Mode: AtMost}
+}
+
+// check returns an error if count doesn't satisfy e.
+func (e Expectation) check(count int) error {
+	var ok bool
+	var what string
+	switch e.Mode {
+	case AtLeast:
+		ok, what = count >= e.Count, "at least"
+	case AtMost:
+		ok, what = count <= e.Count, "at most"
+	default:
+		ok, what = count == e.Count, "exactly"
+	}
+
+	if !ok {
+		return fmt.Errorf("expected %s %s to be called %s %d time(s), but it was called %d time(s)",
+			e.Method, e.URL, what, e.Count, count)
+	}
+	return nil
+}
+
+// Verify checks every expectation against the call counts of the MockTransport, calling
+// t.Errorf for each unmet one.  Only the testing.TB interface is used, so it works with any
+// test framework built on the testing package.
+//
+//	mock.Verify(t,
+//		httpmock.CalledExactly("GET", "https://api.mybiz.com/articles.json", 1),
+//		httpmock.CalledAtMost("POST", "https://api.mybiz.com/articles.json", 2))
+func (m *MockTransport) Verify(t testing.TB, expectations ...Expectation) {
+	t.Helper()
+
+	counts := m.GetCallCountInfo()
+	for _, e := range expectations {
+		if err := e.check(counts[responderKey(e.Method, e.URL)]); err != nil {
+			t.Errorf("httpmock: %s", err)
+		}
+	}
+}
+
+// Verify checks the expectations against DefaultTransport, see MockTransport.Verify.
+func Verify(t testing.TB, expectations ...Expectation) {
+	t.Helper()
+	DefaultTransport.Verify(t, expectations...)
+}
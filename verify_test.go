@@ -0,0 +1,67 @@
+package httpmock
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// fakeTB records the errors reported through it.
+type fakeTB struct {
+	testing.TB
+	errors []string
+	logs   []string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Logf(format string, args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+
+func TestMockTransportVerify(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	RegisterResponder("GET", testUrl, NewStringResponder(200, "hello world"))
+	RegisterResponder("POST", testUrl, NewStringResponder(200, "hello world"))
+
+	for i := 0; i < 2; i++ {
+		if _, err := http.Get(testUrl); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tb := &fakeTB{}
+	Verify(tb,
+		CalledExactly("GET", testUrl, 2),
+		CalledAtLeast("GET", testUrl, 1),
+		CalledAtMost("GET", testUrl, 2),
+		CalledExactly("POST", testUrl, 0),
+	)
+
+	if len(tb.errors) != 0 {
+		t.Fatalf("expected all expectations to be met, got %v", tb.errors)
+	}
+
+	Verify(tb,
+		CalledExactly("GET", testUrl, 1),
+		CalledAtLeast("POST", testUrl, 1),
+		CalledAtMost("GET", testUrl, 1),
+	)
+
+	if len(tb.errors) != 3 {
+		t.Fatalf("expected 3 unmet expectations, got %v", tb.errors)
+	}
+
+	for i, what := range []string{"exactly 1", "at least 1", "at most 1"} {
+		if !strings.Contains(tb.errors[i], what) {
+			t.Fatalf("expected error #%d to contain %q, got %q", i+1, what, tb.errors[i])
+		}
+	}
+}
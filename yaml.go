@@ -0,0 +1,288 @@
+package httpmock
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// yamlResponder is an entry of a YAML fixture, see RegisterResponderFromYAML.
+type yamlResponder struct {
+	line     int
+	method   string
+	url      string
+	status   int
+	headers  http.Header
+	body     *string
+	bodyFile string
+}
+
+// RegisterResponderFromYAML registers the responders described by the YAML document read from r
+// and returns how many were registered.  The document is a list of entries such as:
+//
+//	# articles
+//	- method: GET
+//	  url: https://api.mybiz.com/articles.json
+//	  status: 200
+//	  headers:
+//	    Content-Type: application/json
+//	  body: '[{"id": 1, "name": "My Great Article"}]'
+//
+//	- method: POST
+//	  url: https://api.mybiz.com/articles.json
+//	  status: 201
+//	  body: |
+//	    {"id": 2}
+//
+//	- method: GET
+//	  url: https://api.mybiz.com/large.json
+//	  bodyFile: testdata/large.json
+//
+// method and url are required.  status defaults to 200.  headers is a mapping of header names
+// to values.  body is the response body; bodyFile instead names a file, read at registration
+// time and relative to the current directory, holding the body.  body and bodyFile are mutually
+// exclusive.
+//
+// To keep this package free of dependencies, only the subset of YAML needed by this schema is
+// supported: a block sequence of block mappings, with plain, single-quoted or double-quoted
+// scalars, literal block scalars ("|", "|-" and "|+") and full-line comments.  Indentation must
+// use spaces.
+//
+// Nothing is registered if the document is invalid.
+func (m *MockTransport) RegisterResponderFromYAML(r io.Reader) (int, error) {
+	entries, err := parseYAMLResponders(r)
+	if err != nil {
+		return 0, err
+	}
+
+	responders := make([]Responder, len(entries))
+	for i, e := range entries {
+		var body []byte
+		if e.body != nil {
+			body = []byte(*e.body)
+		} else if e.bodyFile != "" {
+			if body, err = ioutil.ReadFile(e.bodyFile); err != nil {
+				return 0, fmt.Errorf("yaml line %d: %s", e.line, err)
+			}
+		}
+
+		resp := NewBytesResponse(e.status, body)
+		for k, v := range e.headers {
+			resp.Header[k] = v
+		}
+		responders[i] = ResponderFromResponse(resp)
+	}
+
+	for i, e := range entries {
+		m.RegisterResponder(e.method, e.url, responders[i])
+	}
+	return len(entries), nil
+}
+
+// RegisterResponderFromYAML registers the responders described by a YAML document on
+// DefaultTransport, see MockTransport.RegisterResponderFromYAML.
+func RegisterResponderFromYAML(r io.Reader) (int, error) {
+	return DefaultTransport.RegisterResponderFromYAML(r)
+}
+
+// parseYAMLResponders parses the YAML subset documented in RegisterResponderFromYAML.
+func parseYAMLResponders(r io.Reader) ([]*yamlResponder, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var entries []*yamlResponder
+	var current *yamlResponder
+	keyIndent, headersIndent := -1, -1
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || trimmed == "---" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if strings.HasPrefix(line[indent:], "\t") {
+			return nil, fmt.Errorf("yaml line %d: tabs can't be used for indentation", i+1)
+		}
+		content := line[indent:]
+
+		if content == "-" || strings.HasPrefix(content, "- ") {
+			current = &yamlResponder{line: i + 1}
+			entries = append(entries, current)
+			headersIndent = -1
+			content = strings.TrimLeft(content[1:], " ")
+			indent = len(line) - len(content)
+			keyIndent = indent
+			if content == "" {
+				keyIndent = -1
+				continue
+			}
+		}
+
+		if current == nil {
+			return nil, fmt.Errorf("yaml line %d: expected a list entry", i+1)
+		}
+		if keyIndent < 0 {
+			keyIndent = indent
+		}
+
+		key, rest, err := splitYAMLKey(content)
+		if err != nil {
+			return nil, fmt.Errorf("yaml line %d: %s", i+1, err)
+		}
+
+		var value string
+		if strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">") {
+			value, i, err = readYAMLBlock(lines, i, indent, rest)
+		} else {
+			value, err = parseYAMLScalar(rest)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("yaml line %d: %s", i+1, err)
+		}
+
+		switch {
+		case headersIndent >= 0 && indent > keyIndent:
+			if headersIndent == 0 {
+				headersIndent = indent
+			} else if indent != headersIndent {
+				return nil, fmt.Errorf("yaml line %d: bad indentation", i+1)
+			}
+			current.headers.Add(key, value)
+			continue
+		case indent != keyIndent:
+			return nil, fmt.Errorf("yaml line %d: bad indentation", i+1)
+		}
+
+		headersIndent = -1
+		switch key {
+		case "method":
+			current.method = value
+		case "url":
+			current.url = value
+		case "status":
+			if current.status, err = strconv.Atoi(value); err != nil {
+				return nil, fmt.Errorf("yaml line %d: invalid status %q", i+1, value)
+			}
+		case "headers":
+			if rest != "" {
+				return nil, fmt.Errorf("yaml line %d: headers must be a mapping", i+1)
+			}
+			current.headers = http.Header{}
+			headersIndent = 0
+		case "body":
+			current.body = &value
+		case "bodyFile":
+			current.bodyFile = value
+		default:
+			return nil, fmt.Errorf("yaml line %d: unknown field %q", i+1, key)
+		}
+	}
+
+	for _, e := range entries {
+		if e.method == "" || e.url == "" {
+			return nil, fmt.Errorf("yaml line %d: method and url are required", e.line)
+		}
+		if e.body != nil && e.bodyFile != "" {
+			return nil, fmt.Errorf("yaml line %d: body and bodyFile are mutually exclusive", e.line)
+		}
+	}
+	return entries, nil
+}
+
+// splitYAMLKey splits a "key: value" mapping line.
+func splitYAMLKey(content string) (key, rest string, err error) {
+	idx := strings.Index(content, ":")
+	for idx >= 0 && idx+1 < len(content) && content[idx+1] != ' ' {
+		next := strings.Index(content[idx+1:], ":")
+		if next < 0 {
+			idx = -1
+			break
+		}
+		idx += next + 1
+	}
+	if idx <= 0 {
+		return "", "", fmt.Errorf("expected a key: value pair")
+	}
+	return strings.TrimSpace(content[:idx]), strings.TrimSpace(content[idx+1:]), nil
+}
+
+// parseYAMLScalar parses a plain, single-quoted or double-quoted scalar.
+func parseYAMLScalar(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid double-quoted scalar %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("invalid single-quoted scalar %s", s)
+		}
+		return strings.Replace(s[1:len(s)-1], "''", "'", -1), nil
+	}
+
+	if idx := strings.Index(s, " #"); idx >= 0 {
+		s = s[:idx]
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// readYAMLBlock reads the literal block scalar starting after line i, whose key is indented by
+// keyIndent.  header is the block indicator ("|", "|-" or "|+").  It returns the value and the
+// index of the last line of the block.
+func readYAMLBlock(lines []string, i, keyIndent int, header string) (string, int, error) {
+	if header != "|" && header != "|-" && header != "|+" {
+		return "", i, fmt.Errorf("unsupported block scalar %q", header)
+	}
+
+	var block []string
+	blockIndent := -1
+	for i+1 < len(lines) {
+		line := lines[i+1]
+		if strings.TrimSpace(line) == "" {
+			block = append(block, "")
+			i++
+			continue
+		}
+
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if indent <= keyIndent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = indent
+		}
+		if indent < blockIndent {
+			return "", i, fmt.Errorf("bad indentation in block scalar")
+		}
+		block = append(block, line[blockIndent:])
+		i++
+	}
+
+	// trailing empty lines are only kept by the "keep" indicator
+	value := strings.Join(block, "\n")
+	trimmed := strings.TrimRight(value, "\n")
+	switch header {
+	case "|":
+		if trimmed != "" {
+			trimmed += "\n"
+		}
+		return trimmed, i, nil
+	case "|-":
+		return trimmed, i, nil
+	}
+	return value + "\n", i, nil
+}
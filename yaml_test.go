@@ -0,0 +1,113 @@
+package httpmock
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegisterResponderFromYAML(t *testing.T) {
+	Activate()
+	defer DeactivateAndReset()
+
+	dir := t.TempDir()
+	bodyFile := filepath.Join(dir, "large.json")
+	if err := os.WriteFile(bodyFile, []byte(`{"large":true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := `
+# articles
+- method: GET
+  url: https://api.mybiz.com/articles.json
+  status: 200
+  headers:
+    Content-Type: application/json
+    X-Quoted: "a \"b\""
+  body: '[{"id": 1, "name": "It''s great"}]'
+
+- method: POST
+  url: https://api.mybiz.com/articles.json # trailing comment
+  status: 201
+  body: |
+    {"id": 2}
+    {"id": 3}
+
+-
+  method: GET
+  url: https://api.mybiz.com/large.json
+  bodyFile: ` + bodyFile + `
+`
+
+	n, err := RegisterResponderFromYAML(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 3 {
+		t.Fatalf("expected 3 responders, got %d", n)
+	}
+
+	tests := []struct {
+		method, url string
+		status      int
+		body        string
+	}{
+		{"GET", "https://api.mybiz.com/articles.json", 200, `[{"id": 1, "name": "It's great"}]`},
+		{"POST", "https://api.mybiz.com/articles.json", 201, "{\"id\": 2}\n{\"id\": 3}\n"},
+		{"GET", "https://api.mybiz.com/large.json", 200, `{"large":true}`},
+	}
+
+	for _, test := range tests {
+		req, err := http.NewRequest(test.method, test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.StatusCode != test.status || string(data) != test.body {
+			t.Fatalf("%s %s: expected %d %q, got %d %q", test.method, test.url, test.status, test.body, resp.StatusCode, data)
+		}
+
+		if test.status == 200 && test.url == tests[0].url {
+			if v := resp.Header.Get("Content-Type"); v != "application/json" {
+				t.Fatalf("expected Content-Type application/json, got %q", v)
+			}
+			if v := resp.Header.Get("X-Quoted"); v != `a "b"` {
+				t.Fatalf("expected X-Quoted to be unquoted, got %q", v)
+			}
+		}
+	}
+}
+
+func TestRegisterResponderFromYAMLInvalid(t *testing.T) {
+	docs := map[string]string{
+		"missing url":    "- method: GET\n",
+		"unknown field":  "- method: GET\n  url: http://x/\n  foo: bar\n",
+		"bad status":     "- method: GET\n  url: http://x/\n  status: ok\n",
+		"not a list":     "method: GET\n",
+		"bad indent":     "- method: GET\n    url: http://x/\n",
+		"body and file":  "- method: GET\n  url: http://x/\n  body: a\n  bodyFile: b\n",
+		"missing file":   "- method: GET\n  url: http://x/\n  bodyFile: /does/not/exist\n",
+		"folded scalars": "- method: GET\n  url: http://x/\n  body: >\n    a\n",
+	}
+
+	for name, doc := range docs {
+		mock := NewMockTransport()
+		if n, err := mock.RegisterResponderFromYAML(strings.NewReader(doc)); err == nil || n != 0 {
+			t.Fatalf("%s: expected an error and nothing registered, got %d, %v", name, n, err)
+		}
+	}
+}